@@ -1,9 +1,12 @@
 package main
 
 import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/config"
 	"GoogleSheetW/internal/services/googleAPI"
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/sheets/v4"
@@ -14,6 +17,11 @@ import (
 )
 
 func main() {
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "path to the config file")
+	flag.Parse()
+	config.SetPath(configPath)
+
 	fmt.Println("=== Управление Google Sheets таблицами ===")
 
 	// Инициализация контекста
@@ -26,20 +34,23 @@ func main() {
 	}
 
 	// Создание Drive сервиса
-	driveSrv, err := googleAPI.GetDriveService(ctx, cred)
+	driveSrv, err := googleAPI.GetDriveService(ctx, cred, "")
 	if err != nil {
 		log.Fatalf("❌ Ошибка создания Drive сервиса: %v", err)
 	}
 
 	// Создание Sheets сервиса
-	sheetsSrv, err := googleAPI.GetSheetsService(ctx, cred)
+	sheetsSrv, err := googleAPI.GetSheetsService(ctx, cred, "")
 	if err != nil {
 		log.Fatalf("❌ Ошибка создания Sheets сервиса: %v", err)
 	}
 
+	// Таблицы ищутся в той же папке Drive, что и у сервиса
+	folderID := config.GetConfig().App.DriveFolderID
+
 	for {
 		// Получение списка всех таблиц
-		sheetIDMap, err := googleAPI.GetAllSheetIDByName(driveSrv)
+		sheetIDMap, err := googleAPI.GetAllSheetIDByName(driveSrv, folderID, backend.AppPropertyManagedBy, backend.ManagedByValue, backend.AppPropertyFiat)
 		if err != nil {
 			log.Fatalf("❌ Ошибка получения списка таблиц: %v", err)
 		}
@@ -2,10 +2,17 @@ package main
 
 import (
 	"GoogleSheetW/internal/app"
+	"GoogleSheetW/internal/config"
 	"GoogleSheetW/internal/logger"
+	"flag"
 )
 
 func main() {
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "path to the config file")
+	flag.Parse()
+	config.SetPath(configPath)
+
 	log := logger.Get()
 
 	application := app.New()
@@ -0,0 +1,193 @@
+package a1Notation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unbounded обозначает открытую границу диапазона (например, "A10:O" без номера последней строки)
+const Unbounded = -1
+
+// Range описывает диапазон в A1-нотации. Индексы строк и столбцов начинаются с 0,
+// конечные индексы не включаются в диапазон
+type Range struct {
+	Sheet    string
+	StartRow int
+	StartCol int
+	EndRow   int
+	EndCol   int
+}
+
+// Parse разбирает строку вида 'Лист'!A1:B2, Лист!A5 или просто имя листа
+func Parse(s string) (Range, error) {
+	sheet, cells, err := splitSheet(s)
+	if err != nil {
+		return Range{}, err
+	}
+
+	r := Range{Sheet: sheet, EndRow: Unbounded, EndCol: Unbounded}
+	if cells == "" {
+		return r, nil
+	}
+
+	startPart, endPart, hasEnd := strings.Cut(cells, ":")
+	startCol, startRow, err := parseCell(startPart)
+	if err != nil {
+		return Range{}, fmt.Errorf("неверный диапазон %q: %v", s, err)
+	}
+	r.StartCol, r.StartRow = max(startCol, 0), max(startRow, 0)
+
+	if !hasEnd {
+		// Одиночная ячейка; для столбца или строки без пары граница остаётся открытой
+		if startRow != Unbounded {
+			r.EndRow = r.StartRow + 1
+		}
+		if startCol != Unbounded {
+			r.EndCol = r.StartCol + 1
+		}
+		return r, nil
+	}
+
+	endCol, endRow, err := parseCell(endPart)
+	if err != nil {
+		return Range{}, fmt.Errorf("неверный диапазон %q: %v", s, err)
+	}
+	if endCol != Unbounded {
+		r.EndCol = endCol + 1
+	}
+	if endRow != Unbounded {
+		r.EndRow = endRow + 1
+	}
+	return r, nil
+}
+
+// String возвращает диапазон в A1-нотации с экранированным именем листа
+func (r Range) String() string {
+	var b strings.Builder
+	b.WriteString(QuoteSheet(r.Sheet))
+	b.WriteString("!")
+	b.WriteString(ColumnName(r.StartCol))
+	b.WriteString(strconv.Itoa(r.StartRow + 1))
+	if r.EndRow == r.StartRow+1 && r.EndCol == r.StartCol+1 {
+		return b.String()
+	}
+	b.WriteString(":")
+	if r.EndCol == Unbounded {
+		// В A1-нотации нельзя опустить столбец, оставив строку, поэтому берём последний допустимый
+		b.WriteString(ColumnName(maxColumns - 1))
+	} else {
+		b.WriteString(ColumnName(r.EndCol - 1))
+	}
+	if r.EndRow != Unbounded {
+		b.WriteString(strconv.Itoa(r.EndRow))
+	}
+	return b.String()
+}
+
+// Cell возвращает адрес одной ячейки, например 'Лист'!B1
+func Cell(sheet string, row, col int) string {
+	return Range{Sheet: sheet, StartRow: row, StartCol: col, EndRow: row + 1, EndCol: col + 1}.String()
+}
+
+// QuoteSheet заключает имя листа в кавычки по правилам Google Sheets
+func QuoteSheet(sheet string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+}
+
+// maxColumns максимальное количество столбцов на листе Google Sheets
+const maxColumns = 18278
+
+// ColumnName переводит индекс столбца (с 0) в буквенное обозначение: 0 -> A, 26 -> AA
+func ColumnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+// ColumnIndex переводит буквенное обозначение столбца в индекс (с 0)
+func ColumnIndex(name string) (int, error) {
+	if name == "" {
+		return 0, fmt.Errorf("пустое имя столбца")
+	}
+	col := 0
+	for _, ch := range strings.ToUpper(name) {
+		if ch < 'A' || ch > 'Z' {
+			return 0, fmt.Errorf("неверное имя столбца %q", name)
+		}
+		col = col*26 + int(ch-'A') + 1
+	}
+	return col - 1, nil
+}
+
+// ParseCell разбирает адрес ячейки без имени листа, например "A10"
+func ParseCell(cell string) (row, col int, err error) {
+	col, row, err = parseCell(cell)
+	if err != nil {
+		return 0, 0, err
+	}
+	if col == Unbounded || row == Unbounded {
+		return 0, 0, fmt.Errorf("неполный адрес ячейки %q", cell)
+	}
+	return row, col, nil
+}
+
+func splitSheet(s string) (sheet, cells string, err error) {
+	if strings.HasPrefix(s, "'") {
+		var b strings.Builder
+		i := 1
+		for ; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			b.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return "", "", fmt.Errorf("незакрытая кавычка в диапазоне %q", s)
+		}
+		rest := s[i+1:]
+		if rest != "" && !strings.HasPrefix(rest, "!") {
+			return "", "", fmt.Errorf("неверный диапазон %q", s)
+		}
+		return b.String(), strings.TrimPrefix(rest, "!"), nil
+	}
+
+	if idx := strings.LastIndex(s, "!"); idx >= 0 {
+		return s[:idx], s[idx+1:], nil
+	}
+	return s, "", nil
+}
+
+// parseCell возвращает индексы столбца и строки; отсутствующая часть равна Unbounded
+func parseCell(cell string) (col, row int, err error) {
+	i := 0
+	for i < len(cell) && (cell[i] >= 'A' && cell[i] <= 'Z' || cell[i] >= 'a' && cell[i] <= 'z') {
+		i++
+	}
+	col, row = Unbounded, Unbounded
+	if i > 0 {
+		col, err = ColumnIndex(cell[:i])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if i < len(cell) {
+		n, convErr := strconv.Atoi(cell[i:])
+		if convErr != nil || n < 1 {
+			return 0, 0, fmt.Errorf("неверный номер строки в %q", cell)
+		}
+		row = n - 1
+	}
+	if col == Unbounded && row == Unbounded {
+		return 0, 0, fmt.Errorf("пустой адрес ячейки")
+	}
+	return col, row, nil
+}
@@ -1,23 +1,43 @@
 package app
 
 import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/backend/googleBackend"
+	"GoogleSheetW/internal/backend/limitedBackend"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/cache"
+	"GoogleSheetW/internal/cache/fileCache"
 	"GoogleSheetW/internal/cache/localCache"
+	"GoogleSheetW/internal/cache/redisCache"
 	"GoogleSheetW/internal/config"
 	"GoogleSheetW/internal/controller"
+	"GoogleSheetW/internal/layout"
 	"GoogleSheetW/internal/logger"
+	"GoogleSheetW/internal/outbox"
+	"GoogleSheetW/internal/services/googleAPI"
 	"GoogleSheetW/internal/services/sheetsControl"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 )
 
 type App struct {
 	config        *config.Config
 	log           *zap.SugaredLogger
+	backend       backend.SpreadsheetBackend
+	cache         cache.Cache
+	outbox        *outbox.Outbox
 	sheetsControl *sheetsControl.SheetsControl
 	controller    *controller.SheetsController
+	stop          context.CancelFunc // останавливает фоновые задачи: сверку кэша, повтор журнала, обработчики записи
 }
 
 func New() *App {
@@ -25,11 +45,68 @@ func New() *App {
 	log := logger.Get()
 
 	// Инициализация кэша
-	cache := localCache.GetInstance()
+	ctx, stop := context.WithCancel(context.Background())
+	cache, err := newCache(ctx, cfg)
+	if err != nil {
+		log.Errorw("Ошибка инициализации кэша", "cache_type", cfg.App.CacheType, "error", err)
+		panic(err)
+	}
+
+	// Политика повторов для всех запросов к Google API
+	googleAPI.SetRetryPolicy(googleAPI.RetryPolicy{
+		MaxAttempts:  cfg.App.RetryMaxAttempts,
+		InitialDelay: cfg.App.RetryInitialDelay,
+		MaxDelay:     cfg.App.RetryMaxDelay,
+	})
+
+	// Инициализация хранилища таблиц
+	spreadsheetBackend, err := newBackend(ctx, cfg)
+	if err != nil {
+		log.Errorw("Ошибка инициализации хранилища таблиц", "backend", cfg.App.Backend, "error", err)
+		panic(err)
+	}
+
+	// Журнал исходящих данных: данные сохраняются на диск до отправки в Google
+	var pendingWrites *outbox.Outbox
+	if cfg.App.OutboxPath != "" {
+		pendingWrites, err = outbox.Open(cfg.App.OutboxPath)
+		if err != nil {
+			log.Errorw("Ошибка открытия журнала исходящих данных", "path", cfg.App.OutboxPath, "error", err)
+			panic(err)
+		}
+		log.Infow("Журнал исходящих данных открыт", "path", cfg.App.OutboxPath, "pending", pendingWrites.Len())
+	}
+
+	// Раскладка листов: где и какие поля записываются
+	sheetLayout, err := layout.Load(cfg.App.LayoutPath)
+	if err != nil {
+		log.Errorw("Ошибка загрузки раскладки листов", "path", cfg.App.LayoutPath, "error", err)
+		panic(err)
+	}
 
 	// Инициализация сервиса для работы с Google Sheets
-	ctx := context.Background()
-	sheetsCtrl := sheetsControl.New(ctx, cache, cfg.App.GoogleJsonPath)
+	sheetsCtrl := sheetsControl.New(ctx, cache, spreadsheetBackend, sheetsControl.Options{
+		JobWorkers:           cfg.App.JobWorkers,
+		JobQueueSize:         cfg.App.JobQueueSize,
+		JobRetention:         cfg.App.JobRetention,
+		BulkWorkers:          cfg.App.BulkWorkers,
+		Outbox:               pendingWrites,
+		OutboxReplayInterval: cfg.App.OutboxReplayInterval,
+		CacheRefreshInterval: cfg.App.CacheRefreshInterval,
+		AdoptUntagged:        cfg.App.AdoptUntaggedSpreadsheets,
+		FolderTemplate:       cfg.App.DriveFolderTemplate,
+		FiatRegions:          cfg.App.FiatRegions,
+
+		TemplateSpreadsheetID: cfg.App.TemplateSpreadsheetID,
+		TemplateSheetName:     cfg.App.TemplateSheetName,
+
+		Layout:     sheetLayout,
+		RawMaxRows: cfg.App.RawMaxRows,
+
+		RawWindowRows: cfg.App.RawWindowRows,
+		RawWindowAge:  cfg.App.RawWindowAge,
+		RawTimeColumn: cfg.App.RawTimeColumn,
+	})
 
 	// Инициализация HTTP контроллера
 	httpController := controller.NewSheetsController(sheetsCtrl)
@@ -37,17 +114,80 @@ func New() *App {
 	return &App{
 		config:        cfg,
 		log:           log,
+		backend:       spreadsheetBackend,
+		cache:         cache,
+		outbox:        pendingWrites,
 		sheetsControl: sheetsCtrl,
 		controller:    httpController,
+		stop:          stop,
+	}
+}
+
+// newCache выбирает реализацию кэша по конфигурации
+func newCache(ctx context.Context, cfg *config.Config) (cache.Cache, error) {
+	switch cfg.App.CacheType {
+	case "memory":
+		return localCache.GetInstance(), nil
+	case "file":
+		return fileCache.New(cfg.App.CachePath)
+	case "redis":
+		return redisCache.New(ctx, redisCache.Options{
+			Addr:     cfg.App.RedisAddr,
+			Password: cfg.App.RedisPassword,
+			DB:       cfg.App.RedisDB,
+			Prefix:   cfg.App.RedisPrefix,
+		})
+	default:
+		return nil, fmt.Errorf("неизвестный тип кэша: %s", cfg.App.CacheType)
 	}
 }
 
+// newBackend выбирает реализацию хранилища таблиц по конфигурации
+// и оборачивает её ограничителем частоты запросов
+func newBackend(ctx context.Context, cfg *config.Config) (backend.SpreadsheetBackend, error) {
+	var storage backend.SpreadsheetBackend
+	switch cfg.App.Backend {
+	case "google":
+		googleSrv, err := googleBackend.New(ctx, googleBackend.Options{
+			CredentialsPath: cfg.App.GoogleJsonPath,
+			Endpoint:        cfg.App.GoogleEndpoint,
+			FolderID:        cfg.App.DriveFolderID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		storage = googleSrv
+	case "memory":
+		storage = memoryBackend.New()
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища: %s", cfg.App.Backend)
+	}
+
+	// Квоты Google общие для проекта, поэтому все валюты делят один бюджет запросов
+	return limitedBackend.New(storage, limitedBackend.Options{
+		ReadPerMinute:  cfg.App.ReadRequestsPerMinute,
+		WritePerMinute: cfg.App.WriteRequestsPerMinute,
+		Burst:          cfg.App.RateLimitBurst,
+		QueueSize:      cfg.App.RequestQueueSize,
+		MaxWait:        cfg.App.RequestQueueMaxWait,
+	}), nil
+}
+
 func (a *App) setupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Роуты для API
 	mux.HandleFunc("/api/sheets/set-data", a.controller.SetSheetData)
-	mux.HandleFunc("/api/sheets/", a.handleSheetsRequests) // Универсальный обработчик для DELETE запросов
+	mux.HandleFunc("/api/sheets/bulk", a.controller.SetSheetDataBulk)
+	mux.HandleFunc("/api/sheets", a.controller.ListSpreadsheets)
+	mux.HandleFunc("/api/sheets/", a.handleSheetsRequests) // Универсальный обработчик для GET, PUT и DELETE запросов
+	mux.HandleFunc("/api/jobs/", a.controller.GetJob)
+	mux.HandleFunc("/admin/outbox", a.controller.GetOutbox)
+	mux.HandleFunc("/admin/cache/reconcile", a.controller.ReconcileCache)
+
+	if a.config.Metrics.Enabled && a.config.Metrics.Port == a.config.App.Port {
+		mux.HandleFunc("/metrics", a.handleMetrics)
+	}
 
 	// Добавим простой health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -61,10 +201,28 @@ func (a *App) setupRoutes() *http.ServeMux {
 	return mux
 }
 
+// handleMetrics отдаёт метрики сервиса в JSON
+func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := map[string]interface{}{}
+	if limited, ok := a.backend.(*limitedBackend.LimitedBackend); ok {
+		metrics["google_requests"] = map[string]limitedBackend.Stats{
+			"read":  limited.ReadStats(),
+			"write": limited.WriteStats(),
+		}
+	}
+	if a.outbox != nil {
+		metrics["outbox_pending"] = a.outbox.Len()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metrics); err != nil {
+		a.log.Errorw("Ошибка записи метрик", "error", err)
+	}
+}
+
 // handleSheetsRequests универсальный обработчик для маршрутизации запросов к таблицам
 func (a *App) handleSheetsRequests(w http.ResponseWriter, r *http.Request) {
-	// Только DELETE запросы обрабатываем здесь
-	if r.Method != http.MethodDelete {
+	if r.Method != http.MethodDelete && r.Method != http.MethodGet && r.Method != http.MethodPut {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
 		return
 	}
@@ -80,15 +238,30 @@ func (a *App) handleSheetsRequests(w http.ResponseWriter, r *http.Request) {
 	// Проверяем структуру пути
 	parts := strings.Split(path, "/")
 
-	if len(parts) == 1 && parts[0] != "" {
+	switch {
+	case r.Method == http.MethodDelete && len(parts) == 1 && parts[0] != "":
 		// DELETE /api/sheets/{fiat} - удаление всей таблицы
 		a.controller.DeleteSpreadsheet(w, r)
-	} else if len(parts) == 3 && parts[1] == "sheet" && parts[2] != "" {
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] != "":
+		// GET /api/sheets/{fiat} - листы таблицы валюты
+		a.controller.GetSpreadsheet(w, r)
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[1] == "sheet" && parts[2] != "":
 		// DELETE /api/sheets/{fiat}/sheet/{sheetName} - удаление листа
 		a.controller.DeleteSheet(w, r)
-	} else {
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "sheet" && parts[2] != "":
+		// GET /api/sheets/{fiat}/sheet/{sheetName} - данные листа супа
+		a.controller.GetSheet(w, r)
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[1] == "sheet" && parts[2] != "":
+		// PUT /api/sheets/{fiat}/sheet/{sheetName} - запись одного листа супа
+		a.controller.UpsertSheet(w, r)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "raw":
+		// GET /api/sheets/{fiat}/raw - данные листов RAW
+		a.controller.GetRaw(w, r)
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[1] == "raw":
+		// PUT /api/sheets/{fiat}/raw - запись только данных RAW
+		a.controller.UpsertRaw(w, r)
+	default:
 		http.Error(w, "Неверный формат URL", http.StatusBadRequest)
-		return
 	}
 }
 
@@ -104,10 +277,63 @@ func (a *App) Run() error {
 	}
 
 	a.log.Info("API endpoints:")
-	a.log.Info("POST /api/sheets/set-data - установка данных в таблицу")
+	a.log.Info("POST /api/sheets/set-data - установка данных в таблицу (?async=true - в фоне)")
+	a.log.Info("POST /api/sheets/bulk - установка данных нескольких валют одним запросом")
+	a.log.Info("GET /api/jobs/{id} - состояние фоновой задачи записи")
+	a.log.Info("GET /api/sheets - таблицы валют и их листы (?refresh=true - со сверкой с Google)")
+	a.log.Info("GET /api/sheets/{fiat} - листы таблицы валюты (?refresh=true - из Google)")
+	a.log.Info("GET /api/sheets/{fiat}/sheet/{sheetName} - данные листа супа")
+	a.log.Info("GET /api/sheets/{fiat}/raw - данные листов RAW")
+	a.log.Info("PUT /api/sheets/{fiat}/sheet/{sheetName} - запись одного листа супа без RAW")
+	a.log.Info("PUT /api/sheets/{fiat}/raw - запись только данных RAW (?mode=append - дозапись строк)")
 	a.log.Info("DELETE /api/sheets/{fiat} - удаление всей таблицы")
 	a.log.Info("DELETE /api/sheets/{fiat}/sheet/{sheetName} - удаление листа из таблицы")
+	a.log.Info("GET /admin/outbox - данные, ожидающие повторной отправки")
+	a.log.Info("POST /admin/cache/reconcile - сверка кэша с Google Drive")
 	a.log.Info("GET /health - проверка состояния сервиса")
 
-	return server.ListenAndServe()
+	if a.config.Metrics.Enabled {
+		a.log.Info("GET /metrics - метрики сервиса")
+		if a.config.Metrics.Port != a.config.App.Port {
+			a.runMetricsServer()
+		}
+	}
+
+	// По SIGINT/SIGTERM сервер перестаёт принимать запросы, а кэш с отложенным сохранением (CACHE_TYPE=file)
+	// дописывает последние изменения в файл
+	go func() {
+		signals, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		<-signals.Done()
+
+		a.log.Info("Остановка сервера")
+		a.stop()
+		if err := server.Shutdown(context.Background()); err != nil {
+			a.log.Errorw("Ошибка остановки сервера", "error", err)
+		}
+		if closer, ok := a.cache.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				a.log.Errorw("Ошибка сохранения кэша при остановке", "error", err)
+			}
+		}
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runMetricsServer запускает отдельный сервер метрик, если для него указан свой порт
+func (a *App) runMetricsServer() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", a.handleMetrics)
+
+	addr := fmt.Sprintf("%s:%d", a.config.Metrics.Host, a.config.Metrics.Port)
+	go func() {
+		a.log.Infow("Сервер метрик запускается", "address", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			a.log.Errorw("Ошибка сервера метрик", "address", addr, "error", err)
+		}
+	}()
 }
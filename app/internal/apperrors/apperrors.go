@@ -7,6 +7,16 @@ import (
 
 var (
 	ErrCacheNotFound = errors.New("not found")
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobQueueFull  = errors.New("job queue is full")
+
+	// Ошибки хранилища таблиц: таблица удалена или лист не существует (например, удалены вручную в Google)
+	ErrSpreadsheetNotFound = errors.New("spreadsheet not found")
+	ErrSheetNotFound       = errors.New("sheet not found")
+
+	// ErrAppendConflict - лист RAW изменён после дозаписи с неизвестным результатом, и повтор не может
+	// определить, дописаны ли строки
+	ErrAppendConflict = errors.New("raw append conflict")
 )
 
 type ValidationError struct {
@@ -17,3 +27,19 @@ type ValidationError struct {
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("validation failed: field '%s': %s", e.Field, e.Msg)
 }
+
+// StepError - ошибка шага записи данных: на каком шаге запись прервалась
+// и удалось ли отменить изменения, сделанные предыдущими шагами
+type StepError struct {
+	Step       string
+	Err        error
+	RolledBack bool // изменения предыдущих шагов отменены или их не было
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("шаг %s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
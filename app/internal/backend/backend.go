@@ -0,0 +1,48 @@
+package backend
+
+import "google.golang.org/api/sheets/v4"
+
+// Метки Drive appProperties, которыми отмечаются таблицы, созданные сервисом
+const (
+	AppPropertyManagedBy = "managed-by"
+	AppPropertyFiat      = "fiat"
+	ManagedByValue       = "googlesheetw"
+)
+
+// SpreadsheetInfo описывает таблицу, найденную в хранилище
+type SpreadsheetInfo struct {
+	ID      string
+	Title   string
+	Fiat    string // валюта из метки таблицы
+	Managed bool   // таблица отмечена как созданная сервисом
+}
+
+// SheetInfo описывает лист таблицы и размер его сетки
+type SheetInfo struct {
+	SheetID     int64
+	Title       string
+	RowCount    int64
+	ColumnCount int64
+}
+
+// SpreadsheetBackend абстрагирует хранилище таблиц: Google Sheets/Drive или его заменитель в памяти
+type SpreadsheetBackend interface {
+	CreateSpreadsheet(title string) (string, error)            // возвращает ID новой таблицы
+	CopySpreadsheet(templateID, title string) (string, error)  // возвращает ID копии таблицы-шаблона
+	AddPermission(spreadsheetID string, emails []string) error // выдаёт права на запись
+	TagSpreadsheet(spreadsheetID, fiat string) error           // отмечает таблицу как созданную сервисом для валюты
+	SheetIDByName(spreadsheetID, sheetName string) (int64, error)
+	// BatchUpdate применяет структурные запросы одним вызовом: либо все, либо ни одного
+	BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error)
+	WriteValues(spreadsheetID string, data []*sheets.ValueRange) error
+	// AppendValues дописывает строки под таблицей, которая начинается в диапазоне rng, вставляя строки в сетку;
+	// возвращает диапазон дописанных строк
+	AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error)
+	ReadValues(spreadsheetID, sheetName string) ([][]string, error) // значения листа; пустые строки в конце отбрасываются
+	DeleteSheet(spreadsheetID, sheetName string) error
+	DeleteSpreadsheet(spreadsheetID string) error
+	ListSpreadsheets() ([]SpreadsheetInfo, error)
+	ListSheets(spreadsheetID string) ([]SheetInfo, error)
+	EnsureFolder(path []string) (string, error)        // ID папки по пути от корневой папки; недостающие папки создаются
+	MoveToFolder(spreadsheetID, folderID string) error // переносит таблицу в папку
+}
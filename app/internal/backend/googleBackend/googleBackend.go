@@ -0,0 +1,245 @@
+package googleBackend
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/services/googleAPI"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sheets/v4"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Options настройки подключения к Google
+type Options struct {
+	CredentialsPath string // файл учетных данных сервисного аккаунта
+	Endpoint        string // адрес замены Google API; если задан, запросы идут без авторизации
+	FolderID        string // корневая папка Drive, в дереве которой создаются и ищутся таблицы; пусто - весь Drive
+}
+
+// GoogleBackend реализует backend.SpreadsheetBackend поверх Google Sheets и Drive API
+type GoogleBackend struct {
+	sheetSrv *sheets.Service
+	driveSrv *drive.Service
+	folderID string
+
+	folders  map[string]string // путь папки -> ID, чтобы не искать папку при каждом создании таблицы
+	foldersM sync.Mutex
+
+	driveID    string // общий диск корневой папки; определяется при первом обращении к Drive
+	driveKnown bool
+	driveM     sync.Mutex
+}
+
+// New создаёт клиентов Google API по файлу учетных данных сервисного аккаунта.
+// Если задан endpoint, клиенты обращаются к нему без авторизации и файл учетных данных не читается
+func New(ctx context.Context, opts Options) (*GoogleBackend, error) {
+	var cred *google.Credentials
+	if opts.Endpoint == "" {
+		var err error
+		cred, err = googleAPI.GetCredentials(ctx, opts.CredentialsPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sheetSrv, err := googleAPI.GetSheetsService(ctx, cred, opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	driveSrv, err := googleAPI.GetDriveService(ctx, cred, opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleBackend{
+		sheetSrv: sheetSrv,
+		driveSrv: driveSrv,
+		folderID: opts.FolderID,
+		folders:  make(map[string]string),
+	}, nil
+}
+
+// CreateSpreadsheet создаёт таблицу и, если задана папка, переносит её туда,
+// чтобы таблица нашлась при следующем поиске
+func (g *GoogleBackend) CreateSpreadsheet(title string) (string, error) {
+	id, err := googleAPI.CreateSheet(g.sheetSrv, title)
+	if err != nil || g.folderID == "" {
+		return id, err
+	}
+	if err := googleAPI.MoveToFolder(g.driveSrv, id, g.folderID); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// CopySpreadsheet копирует шаблон; копия создаётся сразу в корневой папке, если она задана.
+// Ошибка не классифицируется: отсутствие шаблона - ошибка настройки, а не признак удалённой таблицы валюты
+func (g *GoogleBackend) CopySpreadsheet(templateID, title string) (string, error) {
+	return googleAPI.CopySpreadsheet(g.driveSrv, templateID, title, g.folderID)
+}
+
+func (g *GoogleBackend) AddPermission(spreadsheetID string, emails []string) error {
+	return classify(googleAPI.AddPermission(g.driveSrv, spreadsheetID, &emails))
+}
+
+func (g *GoogleBackend) TagSpreadsheet(spreadsheetID, fiat string) error {
+	return classify(googleAPI.SetAppProperties(g.driveSrv, spreadsheetID, map[string]string{
+		backend.AppPropertyManagedBy: backend.ManagedByValue,
+		backend.AppPropertyFiat:      fiat,
+	}))
+}
+
+func (g *GoogleBackend) BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	replies, err := googleAPI.BatchUpdate(g.sheetSrv, spreadsheetID, requests)
+	return replies, classify(err)
+}
+
+func (g *GoogleBackend) SheetIDByName(spreadsheetID, sheetName string) (int64, error) {
+	id, err := googleAPI.SheetIDByName(g.sheetSrv, spreadsheetID, sheetName)
+	return id, classify(err)
+}
+
+func (g *GoogleBackend) WriteValues(spreadsheetID string, data []*sheets.ValueRange) error {
+	return classify(googleAPI.WriteToSheet(g.sheetSrv, spreadsheetID, data))
+}
+
+func (g *GoogleBackend) AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	updated, err := googleAPI.AppendToSheet(g.sheetSrv, spreadsheetID, rng, rows)
+	return updated, classify(err)
+}
+
+func (g *GoogleBackend) DeleteSheet(spreadsheetID, sheetName string) error {
+	return classify(googleAPI.DeleteSheetByName(g.sheetSrv, spreadsheetID, sheetName))
+}
+
+func (g *GoogleBackend) DeleteSpreadsheet(spreadsheetID string) error {
+	return classify(googleAPI.DeleteSpreadsheetByID(g.driveSrv, spreadsheetID))
+}
+
+// sharedDrive возвращает общий диск корневой папки, чтобы файлы искались только на нём;
+// пусто - корневая папка не задана или лежит не на общем диске
+func (g *GoogleBackend) sharedDrive() (string, error) {
+	if g.folderID == "" {
+		return "", nil
+	}
+
+	g.driveM.Lock()
+	defer g.driveM.Unlock()
+
+	if !g.driveKnown {
+		driveID, err := googleAPI.FolderDriveID(g.driveSrv, g.folderID)
+		if err != nil {
+			return "", err
+		}
+		g.driveID, g.driveKnown = driveID, true
+	}
+	return g.driveID, nil
+}
+
+func (g *GoogleBackend) ListSpreadsheets() ([]backend.SpreadsheetInfo, error) {
+	driveID, err := g.sharedDrive()
+	if err != nil {
+		return nil, err
+	}
+	var folderIDs []string
+	if g.folderID != "" {
+		// Таблицы ищутся во всём дереве корневой папки, включая подпапки
+		tree, err := googleAPI.ListFolderTree(g.driveSrv, driveID, g.folderID)
+		if err != nil {
+			return nil, err
+		}
+		folderIDs = tree
+	}
+	files, err := googleAPI.ListSpreadsheets(g.driveSrv, driveID, folderIDs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backend.SpreadsheetInfo, 0, len(files))
+	for _, file := range files {
+		result = append(result, backend.SpreadsheetInfo{
+			ID:      file.Id,
+			Title:   file.Name,
+			Fiat:    file.AppProperties[backend.AppPropertyFiat],
+			Managed: file.AppProperties[backend.AppPropertyManagedBy] == backend.ManagedByValue,
+		})
+	}
+	return result, nil
+}
+
+func (g *GoogleBackend) ReadValues(spreadsheetID, sheetName string) ([][]string, error) {
+	values, err := googleAPI.ReadSheet(g.sheetSrv, spreadsheetID, sheetName)
+	return values, classify(err)
+}
+
+func (g *GoogleBackend) ListSheets(spreadsheetID string) ([]backend.SheetInfo, error) {
+	properties, err := googleAPI.GetSheetsProperties(g.sheetSrv, spreadsheetID)
+	if err != nil {
+		return nil, classify(err)
+	}
+	result := make([]backend.SheetInfo, 0, len(properties))
+	for _, p := range properties {
+		info := backend.SheetInfo{SheetID: p.SheetId, Title: p.Title}
+		if p.GridProperties != nil {
+			info.RowCount = p.GridProperties.RowCount
+			info.ColumnCount = p.GridProperties.ColumnCount
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// EnsureFolder возвращает ID папки по пути от корневой папки (или корня Drive), создавая недостающие папки
+func (g *GoogleBackend) EnsureFolder(path []string) (string, error) {
+	driveID, err := g.sharedDrive()
+	if err != nil {
+		return "", err
+	}
+
+	g.foldersM.Lock()
+	defer g.foldersM.Unlock()
+
+	parentID := g.folderID
+	if parentID == "" {
+		parentID = "root"
+	}
+	for i, name := range path {
+		key := strings.Join(path[:i+1], "/")
+		if id, ok := g.folders[key]; ok {
+			parentID = id
+			continue
+		}
+		id, err := googleAPI.EnsureFolder(g.driveSrv, driveID, parentID, name)
+		if err != nil {
+			return "", classify(err)
+		}
+		g.folders[key] = id
+		parentID = id
+	}
+	return parentID, nil
+}
+
+func (g *GoogleBackend) MoveToFolder(spreadsheetID, folderID string) error {
+	return classify(googleAPI.MoveToFolder(g.driveSrv, spreadsheetID, folderID))
+}
+
+// classify помечает ошибки Google об удалённой таблице или несуществующем листе
+// ошибками apperrors.ErrSpreadsheetNotFound и apperrors.ErrSheetNotFound
+func classify(err error) error {
+	var apiErr *googleapi.Error
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+	switch {
+	case apiErr.Code == http.StatusNotFound:
+		return fmt.Errorf("%w: %w", apperrors.ErrSpreadsheetNotFound, err)
+	case apiErr.Code == http.StatusBadRequest &&
+		(strings.Contains(apiErr.Message, "Unable to parse range") || strings.Contains(apiErr.Message, "No grid with id")):
+		return fmt.Errorf("%w: %w", apperrors.ErrSheetNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package limitedBackend
+
+import (
+	"GoogleSheetW/internal/backend"
+	"google.golang.org/api/sheets/v4"
+	"time"
+)
+
+// Options задаёт бюджеты запросов. Нулевая частота отключает ограничение для своего типа запросов
+type Options struct {
+	ReadPerMinute  int
+	WritePerMinute int
+	Burst          int // сколько запросов можно отправить подряд без ожидания
+	QueueSize      int // мест в очереди ожидающих запросов каждого типа
+	// MaxWait - максимальное время ожидания места в очереди и токена; 0 - без ограничения.
+	// Не дождавшийся вызов хранилища не выполняется и возвращает ошибку, а запись откладывается, как при
+	// недоступности Google: так при перегрузке запросы не копятся в очереди бесконечно
+	MaxWait time.Duration
+}
+
+// LimitedBackend делит бюджет запросов к хранилищу между всеми валютами, раздельно для чтения и записи.
+// Каждый вызов метода ждёт токен своего типа и только затем передаётся обёрнутому backend.SpreadsheetBackend.
+// Повторы внутри одного вызова (см. googleAPI.RetryPolicy) бюджет не тратят: их сдерживает пауза между попытками
+type LimitedBackend struct {
+	backend backend.SpreadsheetBackend
+	read    *limiter
+	write   *limiter
+}
+
+// New оборачивает хранилище ограничителем частоты запросов
+func New(b backend.SpreadsheetBackend, opts Options) *LimitedBackend {
+	return &LimitedBackend{
+		backend: b,
+		read:    newLimiter("read", opts.ReadPerMinute, opts.Burst, opts.QueueSize, opts.MaxWait),
+		write:   newLimiter("write", opts.WritePerMinute, opts.Burst, opts.QueueSize, opts.MaxWait),
+	}
+}
+
+// ReadStats возвращает метрики очереди запросов на чтение
+func (l *LimitedBackend) ReadStats() Stats {
+	return l.read.stats()
+}
+
+// WriteStats возвращает метрики очереди запросов на запись
+func (l *LimitedBackend) WriteStats() Stats {
+	return l.write.stats()
+}
+
+func (l *LimitedBackend) CreateSpreadsheet(title string) (string, error) {
+	if err := l.write.wait(); err != nil {
+		return "", err
+	}
+	return l.backend.CreateSpreadsheet(title)
+}
+
+func (l *LimitedBackend) CopySpreadsheet(templateID, title string) (string, error) {
+	if err := l.write.wait(); err != nil {
+		return "", err
+	}
+	return l.backend.CopySpreadsheet(templateID, title)
+}
+
+func (l *LimitedBackend) AddPermission(spreadsheetID string, emails []string) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.AddPermission(spreadsheetID, emails)
+}
+
+func (l *LimitedBackend) TagSpreadsheet(spreadsheetID, fiat string) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.TagSpreadsheet(spreadsheetID, fiat)
+}
+
+func (l *LimitedBackend) SheetIDByName(spreadsheetID, sheetName string) (int64, error) {
+	if err := l.read.wait(); err != nil {
+		return 0, err
+	}
+	return l.backend.SheetIDByName(spreadsheetID, sheetName)
+}
+
+func (l *LimitedBackend) BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	if err := l.write.wait(); err != nil {
+		return nil, err
+	}
+	return l.backend.BatchUpdate(spreadsheetID, requests)
+}
+
+func (l *LimitedBackend) WriteValues(spreadsheetID string, data []*sheets.ValueRange) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.WriteValues(spreadsheetID, data)
+}
+
+func (l *LimitedBackend) AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	if err := l.write.wait(); err != nil {
+		return "", err
+	}
+	return l.backend.AppendValues(spreadsheetID, rng, rows)
+}
+
+func (l *LimitedBackend) ReadValues(spreadsheetID, sheetName string) ([][]string, error) {
+	if err := l.read.wait(); err != nil {
+		return nil, err
+	}
+	return l.backend.ReadValues(spreadsheetID, sheetName)
+}
+
+func (l *LimitedBackend) DeleteSheet(spreadsheetID, sheetName string) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.DeleteSheet(spreadsheetID, sheetName)
+}
+
+func (l *LimitedBackend) DeleteSpreadsheet(spreadsheetID string) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.DeleteSpreadsheet(spreadsheetID)
+}
+
+func (l *LimitedBackend) ListSpreadsheets() ([]backend.SpreadsheetInfo, error) {
+	if err := l.read.wait(); err != nil {
+		return nil, err
+	}
+	return l.backend.ListSpreadsheets()
+}
+
+func (l *LimitedBackend) ListSheets(spreadsheetID string) ([]backend.SheetInfo, error) {
+	if err := l.read.wait(); err != nil {
+		return nil, err
+	}
+	return l.backend.ListSheets(spreadsheetID)
+}
+
+// EnsureFolder считается записью: недостающие папки создаются
+func (l *LimitedBackend) EnsureFolder(path []string) (string, error) {
+	if err := l.write.wait(); err != nil {
+		return "", err
+	}
+	return l.backend.EnsureFolder(path)
+}
+
+func (l *LimitedBackend) MoveToFolder(spreadsheetID, folderID string) error {
+	if err := l.write.wait(); err != nil {
+		return err
+	}
+	return l.backend.MoveToFolder(spreadsheetID, folderID)
+}
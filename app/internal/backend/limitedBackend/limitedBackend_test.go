@@ -0,0 +1,57 @@
+package limitedBackend
+
+import (
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/backend/recordingBackend"
+	"testing"
+	"time"
+)
+
+// Вызовы записи ждут токен, а чтение тратит собственный бюджет и не ждёт записей
+func TestLimitedBackendThrottlesCalls(t *testing.T) {
+	rec := recordingBackend.New(memoryBackend.New())
+	// 600 в минуту - токен каждые 100 мс
+	limited := New(rec, Options{ReadPerMinute: 600, WritePerMinute: 600, Burst: 1, QueueSize: 4})
+
+	start := time.Now()
+	for range 3 {
+		if _, err := limited.CreateSpreadsheet("USD"); err != nil {
+			t.Fatalf("CreateSpreadsheet: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("три записи за %s, want не меньше двух интервалов токена", elapsed)
+	}
+	if stats := limited.WriteStats(); stats.Requests != 3 || stats.Delayed != 2 {
+		t.Fatalf("метрики записи %+v, want 3 запроса, 2 с ожиданием", stats)
+	}
+
+	if _, err := limited.ListSpreadsheets(); err != nil {
+		t.Fatalf("ListSpreadsheets: %v", err)
+	}
+	if stats := limited.ReadStats(); stats.Requests != 1 || stats.Delayed != 0 {
+		t.Fatalf("метрики чтения %+v, want 1 запрос без ожидания", stats)
+	}
+	if n := len(rec.Calls()); n != 4 {
+		t.Fatalf("вызовов хранилища %d, want 4", n)
+	}
+}
+
+// Вызов, который не дождался токена за MaxWait, возвращает ошибку и не доходит до хранилища
+func TestLimitedBackendMaxWait(t *testing.T) {
+	rec := recordingBackend.New(memoryBackend.New())
+	limited := New(rec, Options{WritePerMinute: 1, Burst: 1, QueueSize: 1, MaxWait: 50 * time.Millisecond})
+
+	if _, err := limited.CreateSpreadsheet("USD"); err != nil {
+		t.Fatalf("CreateSpreadsheet: %v", err)
+	}
+	if _, err := limited.CreateSpreadsheet("EUR"); err == nil {
+		t.Fatal("вторая запись прошла без токена")
+	}
+	if n := len(rec.Calls()); n != 1 {
+		t.Fatalf("вызовов хранилища %d, want 1", n)
+	}
+	if stats := limited.WriteStats(); stats.Timeouts != 1 {
+		t.Fatalf("метрики записи %+v, want 1 таймаут", stats)
+	}
+}
@@ -0,0 +1,164 @@
+package limitedBackend
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket - ведро токенов: пополняется со скоростью rate в секунду до capacity
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+	mu       sync.Mutex
+}
+
+func newTokenBucket(perMinute, burst int) *tokenBucket {
+	capacity := float64(max(burst, 1))
+	return &tokenBucket{
+		tokens:   capacity,
+		capacity: capacity,
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// reserve забирает токен и возвращает, сколько нужно подождать до его появления.
+// Токены можно занять в долг: следующие вызовы будут ждать дольше, сохраняя порядок очереди
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel возвращает токен, который так и не был использован
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.capacity, b.tokens+1)
+}
+
+// Stats - метрики очереди запросов одного типа
+type Stats struct {
+	QueueDepth       int64   `json:"queue_depth"`    // запросов ждут сейчас
+	QueueCapacity    int     `json:"queue_capacity"` // мест в очереди
+	Requests         int64   `json:"requests"`       // всего пропущено запросов
+	Delayed          int64   `json:"delayed"`        // из них ждали токен
+	Timeouts         int64   `json:"timeouts"`       // не дождались очереди
+	WaitTotalSeconds float64 `json:"wait_total_seconds"`
+	WaitMaxSeconds   float64 `json:"wait_max_seconds"`
+	WaitLastSeconds  float64 `json:"wait_last_seconds"`
+}
+
+// limiter пропускает запросы с заданной частотой. Ожидающие запросы стоят в очереди
+// ограниченного размера; при переполнении вызывающие ждут места в очереди, а не получают ошибку
+type limiter struct {
+	name    string
+	bucket  *tokenBucket
+	queue   chan struct{}
+	maxWait time.Duration
+
+	depth    atomic.Int64
+	requests atomic.Int64
+	delayed  atomic.Int64
+	timeouts atomic.Int64
+
+	waitTotal time.Duration
+	waitMax   time.Duration
+	waitLast  time.Duration
+	mu        sync.Mutex
+}
+
+func newLimiter(name string, perMinute, burst, queueSize int, maxWait time.Duration) *limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &limiter{
+		name:    name,
+		bucket:  newTokenBucket(perMinute, burst),
+		queue:   make(chan struct{}, max(queueSize, 1)),
+		maxWait: maxWait,
+	}
+}
+
+// wait блокирует вызывающего, пока запрос не может быть отправлен
+func (l *limiter) wait() error {
+	if l == nil {
+		return nil
+	}
+
+	start := time.Now()
+	l.depth.Add(1)
+	defer l.depth.Add(-1)
+
+	var deadline <-chan time.Time
+	if l.maxWait > 0 {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	case <-deadline:
+		l.timeouts.Add(1)
+		return fmt.Errorf("превышено время ожидания очереди запросов %s (%s)", l.name, l.maxWait)
+	}
+	defer func() { <-l.queue }()
+
+	if delay := l.bucket.reserve(); delay > 0 {
+		if l.maxWait > 0 && time.Since(start)+delay > l.maxWait {
+			l.bucket.cancel()
+			l.timeouts.Add(1)
+			return fmt.Errorf("превышено время ожидания очереди запросов %s (%s)", l.name, l.maxWait)
+		}
+		l.delayed.Add(1)
+		time.Sleep(delay)
+	}
+
+	l.requests.Add(1)
+	l.record(time.Since(start))
+	return nil
+}
+
+func (l *limiter) record(waited time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.waitTotal += waited
+	l.waitLast = waited
+	l.waitMax = max(l.waitMax, waited)
+}
+
+func (l *limiter) stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Stats{
+		QueueDepth:       l.depth.Load(),
+		QueueCapacity:    cap(l.queue),
+		Requests:         l.requests.Load(),
+		Delayed:          l.delayed.Load(),
+		Timeouts:         l.timeouts.Load(),
+		WaitTotalSeconds: l.waitTotal.Seconds(),
+		WaitMaxSeconds:   l.waitMax.Seconds(),
+		WaitLastSeconds:  l.waitLast.Seconds(),
+	}
+}
@@ -0,0 +1,250 @@
+package memoryBackend
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/apperrors"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"strconv"
+)
+
+// BatchUpdate применяет запросы к копии таблицы и заменяет таблицу только при успехе всех запросов,
+// как spreadsheets.batchUpdate. Поддерживаются AddSheet, DuplicateSheet, DeleteSheet, AppendDimension,
+// SetBasicFilter и UpdateCells (значения ячеек)
+func (m *MemoryBackend) BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	draft := ss.clone()
+	replies := make([]*sheets.Response, 0, len(requests))
+	for i, request := range requests {
+		reply, err := draft.apply(request)
+		if err != nil {
+			return nil, fmt.Errorf("Не удалось выполнить запрос %d: %w", i, err)
+		}
+		replies = append(replies, reply)
+	}
+	m.spreadsheets[spreadsheetID] = draft
+	return replies, nil
+}
+
+// clone возвращает копию таблицы со всеми листами
+func (ss *spreadsheet) clone() *spreadsheet {
+	copied := *ss
+	copied.permissions = append([]string(nil), ss.permissions...)
+	copied.sheets = make([]*sheet, len(ss.sheets))
+	for i, sh := range ss.sheets {
+		copied.sheets[i] = sh.clone(sh.id, sh.title)
+	}
+	return &copied
+}
+
+func (ss *spreadsheet) apply(request *sheets.Request) (*sheets.Response, error) {
+	reply := &sheets.Response{}
+	switch {
+	case request.AddSheet != nil:
+		props := request.AddSheet.Properties
+		if ss.sheet(props.Title) != nil {
+			return nil, fmt.Errorf("лист %q уже существует", props.Title)
+		}
+		sh, err := ss.addSheetWithID(props.SheetId, props.Title)
+		if err != nil {
+			return nil, err
+		}
+		if grid := props.GridProperties; grid != nil {
+			if grid.RowCount > 0 {
+				sh.rows = grid.RowCount
+			}
+			if grid.ColumnCount > 0 {
+				sh.cols = grid.ColumnCount
+			}
+		}
+		reply.AddSheet = &sheets.AddSheetResponse{Properties: sh.properties()}
+	case request.DuplicateSheet != nil:
+		dup := request.DuplicateSheet
+		source := ss.sheetByID(dup.SourceSheetId)
+		if source == nil {
+			return nil, fmt.Errorf("No grid with id: %d: %w", dup.SourceSheetId, apperrors.ErrSheetNotFound)
+		}
+		if ss.sheet(dup.NewSheetName) != nil {
+			return nil, fmt.Errorf("лист %q уже существует", dup.NewSheetName)
+		}
+		sh, err := ss.addSheetWithID(dup.NewSheetId, dup.NewSheetName)
+		if err != nil {
+			return nil, err
+		}
+		*sh = *source.clone(sh.id, sh.title)
+		reply.DuplicateSheet = &sheets.DuplicateSheetResponse{Properties: sh.properties()}
+	case request.DeleteSheet != nil:
+		for i, sh := range ss.sheets {
+			if sh.id == request.DeleteSheet.SheetId {
+				if len(ss.sheets) == 1 {
+					return nil, fmt.Errorf("нельзя удалить единственный лист таблицы")
+				}
+				ss.sheets = append(ss.sheets[:i], ss.sheets[i+1:]...)
+				return reply, nil
+			}
+		}
+		return nil, fmt.Errorf("No grid with id: %d: %w", request.DeleteSheet.SheetId, apperrors.ErrSheetNotFound)
+	case request.AppendDimension != nil:
+		dim := request.AppendDimension
+		sh := ss.sheetByID(dim.SheetId)
+		if sh == nil {
+			return nil, fmt.Errorf("No grid with id: %d: %w", dim.SheetId, apperrors.ErrSheetNotFound)
+		}
+		if dim.Dimension == "COLUMNS" {
+			sh.cols += dim.Length
+		} else {
+			sh.rows += dim.Length
+		}
+	case request.DeleteDimension != nil:
+		rng := request.DeleteDimension.Range
+		sh := ss.sheetByID(rng.SheetId)
+		if sh == nil {
+			return nil, fmt.Errorf("No grid with id: %d: %w", rng.SheetId, apperrors.ErrSheetNotFound)
+		}
+		if err := sh.deleteDimension(rng); err != nil {
+			return nil, err
+		}
+	case request.SetBasicFilter != nil:
+		rng := request.SetBasicFilter.Filter.Range
+		sh := ss.sheetByID(rng.SheetId)
+		if sh == nil {
+			return nil, fmt.Errorf("No grid with id: %d: %w", rng.SheetId, apperrors.ErrSheetNotFound)
+		}
+		filter := *rng
+		// Нулевой конец диапазона, как в Google, означает фильтр до конца листа
+		if filter.EndRowIndex > 0 {
+			filter.EndRowIndex = min(filter.EndRowIndex, sh.rows)
+		}
+		if filter.EndColumnIndex > 0 {
+			filter.EndColumnIndex = min(filter.EndColumnIndex, sh.cols)
+		}
+		sh.filter = &filter
+	case request.UpdateCells != nil:
+		if err := ss.updateCells(request.UpdateCells); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("запрос не поддерживается хранилищем в памяти")
+	}
+	return reply, nil
+}
+
+// deleteDimension удаляет строки или столбцы листа; ячейки ниже или правее сдвигаются
+func (sh *sheet) deleteDimension(rng *sheets.DimensionRange) error {
+	size := sh.rows
+	if rng.Dimension == "COLUMNS" {
+		size = sh.cols
+	}
+	if rng.StartIndex < 0 || rng.EndIndex <= rng.StartIndex || rng.EndIndex > size {
+		return fmt.Errorf("Invalid requests[0].deleteDimension: диапазон %d:%d за пределами сетки", rng.StartIndex, rng.EndIndex)
+	}
+	if rng.EndIndex-rng.StartIndex == size {
+		return fmt.Errorf("Invalid requests[0].deleteDimension: You can't delete all the rows on the sheet")
+	}
+	start, end := int(rng.StartIndex), int(rng.EndIndex)
+	if rng.Dimension == "COLUMNS" {
+		for i, row := range sh.cells {
+			if start < len(row) {
+				sh.cells[i] = append(row[:start], row[min(end, len(row)):]...)
+			}
+		}
+		sh.cols -= rng.EndIndex - rng.StartIndex
+	} else {
+		if start < len(sh.cells) {
+			sh.cells = append(sh.cells[:start], sh.cells[min(end, len(sh.cells)):]...)
+		}
+		sh.rows -= rng.EndIndex - rng.StartIndex
+	}
+	sh.trim()
+	return nil
+}
+
+// addSheetWithID добавляет пустой лист; id > 0 задаёт ID листа заранее, как в AddSheetRequest
+func (ss *spreadsheet) addSheetWithID(id int64, title string) (*sheet, error) {
+	if id <= 0 {
+		return ss.addSheet(title), nil
+	}
+	if ss.sheetByID(id) != nil {
+		return nil, fmt.Errorf("лист с ID %d уже существует", id)
+	}
+	sh := &sheet{id: id, title: title, rows: DefaultRowCount, cols: DefaultColumnCount}
+	ss.sheets = append(ss.sheets, sh)
+	ss.nextSheetID = max(ss.nextSheetID, id+1)
+	return sh, nil
+}
+
+// updateCells записывает значения строк в диапазон; ячейки диапазона, не покрытые строками, очищаются.
+// Отсутствующий конец диапазона означает границу листа
+func (ss *spreadsheet) updateCells(req *sheets.UpdateCellsRequest) error {
+	if req.Range == nil {
+		return fmt.Errorf("UpdateCells без диапазона не поддерживается")
+	}
+	rng := req.Range
+	sh := ss.sheetByID(rng.SheetId)
+	if sh == nil {
+		return fmt.Errorf("No grid with id: %d: %w", rng.SheetId, apperrors.ErrSheetNotFound)
+	}
+	r := a1Notation.Range{
+		StartRow: int(rng.StartRowIndex),
+		StartCol: int(rng.StartColumnIndex),
+		EndRow:   a1Notation.Unbounded,
+		EndCol:   a1Notation.Unbounded,
+	}
+	if rng.EndRowIndex > 0 {
+		r.EndRow = int(rng.EndRowIndex)
+	}
+	if rng.EndColumnIndex > 0 {
+		r.EndCol = int(rng.EndColumnIndex)
+	}
+	for i, row := range req.Rows {
+		if int64(r.StartRow+i) >= sh.rows || int64(r.StartCol+len(row.Values)) > sh.cols {
+			return fmt.Errorf("Range exceeds grid limits. Max rows: %d, max columns: %d", sh.rows, sh.cols)
+		}
+	}
+
+	sh.clear(r)
+	for i, row := range req.Rows {
+		for j, cell := range row.Values {
+			sh.set(r.StartRow+i, r.StartCol+j, cellString(cell))
+		}
+	}
+	sh.trim()
+	return nil
+}
+
+// cellString возвращает значение ячейки так, как его хранит хранилище в памяти
+func cellString(cell *sheets.CellData) string {
+	if cell == nil || cell.UserEnteredValue == nil {
+		return ""
+	}
+	v := cell.UserEnteredValue
+	switch {
+	case v.FormulaValue != nil:
+		return *v.FormulaValue
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.NumberValue != nil:
+		return strconv.FormatFloat(*v.NumberValue, 'f', -1, 64)
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	default:
+		return ""
+	}
+}
+
+func (sh *sheet) properties() *sheets.SheetProperties {
+	return &sheets.SheetProperties{
+		SheetId: sh.id,
+		Title:   sh.title,
+		GridProperties: &sheets.GridProperties{
+			RowCount:    sh.rows,
+			ColumnCount: sh.cols,
+		},
+	}
+}
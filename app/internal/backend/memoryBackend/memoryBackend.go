@@ -0,0 +1,488 @@
+package memoryBackend
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Размер сетки нового листа, как у Google Sheets по умолчанию
+const (
+	DefaultRowCount    = 1000
+	DefaultColumnCount = 26
+)
+
+// MemoryBackend реализует backend.SpreadsheetBackend в памяти: хранит таблицы, листы и значения ячеек.
+// Используется для запуска сервиса и тестов без доступа к Google
+type MemoryBackend struct {
+	spreadsheets map[string]*spreadsheet
+	folders      map[string]string // путь папки -> ID
+	nextID       int
+	mu           sync.RWMutex
+}
+
+type spreadsheet struct {
+	id          string
+	title       string
+	sheets      []*sheet
+	permissions []string
+	nextSheetID int64
+	fiat        string // метка валюты, как appProperties в Drive
+	managed     bool
+	folderID    string // папка таблицы; пусто - корень
+}
+
+type sheet struct {
+	id     int64
+	title  string
+	rows   int64
+	cols   int64
+	cells  [][]string
+	filter *sheets.GridRange
+}
+
+// New создаёт пустое хранилище таблиц
+func New() *MemoryBackend {
+	return &MemoryBackend{
+		spreadsheets: make(map[string]*spreadsheet),
+		folders:      make(map[string]string),
+	}
+}
+
+func (m *MemoryBackend) CreateSpreadsheet(title string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	ss := &spreadsheet{
+		id:    fmt.Sprintf("mem-%d", m.nextID),
+		title: title,
+	}
+	// Как и Google, новая таблица создаётся с одним пустым листом
+	ss.addSheet("Sheet1")
+	m.spreadsheets[ss.id] = ss
+	return ss.id, nil
+}
+
+// CopySpreadsheet создаёт копию таблицы-шаблона со всеми листами и значениями.
+// Доступы, метки и папка шаблона, как и в Drive, не копируются
+func (m *MemoryBackend) CopySpreadsheet(templateID, title string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	template, ok := m.spreadsheets[templateID]
+	if !ok {
+		return "", fmt.Errorf("Не удалось скопировать шаблон %s: таблица не найдена", templateID)
+	}
+	m.nextID++
+	ss := &spreadsheet{
+		id:          fmt.Sprintf("mem-%d", m.nextID),
+		title:       title,
+		nextSheetID: template.nextSheetID,
+	}
+	for _, sh := range template.sheets {
+		ss.sheets = append(ss.sheets, sh.clone(sh.id, sh.title))
+	}
+	m.spreadsheets[ss.id] = ss
+	return ss.id, nil
+}
+
+func (m *MemoryBackend) AddPermission(spreadsheetID string, emails []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return err
+	}
+	ss.permissions = append(ss.permissions, emails...)
+	return nil
+}
+
+func (m *MemoryBackend) TagSpreadsheet(spreadsheetID, fiat string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return err
+	}
+	ss.fiat = fiat
+	ss.managed = true
+	return nil
+}
+
+func (m *MemoryBackend) SheetIDByName(spreadsheetID, sheetName string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return 0, err
+	}
+	sh := ss.sheet(sheetName)
+	if sh == nil {
+		return 0, fmt.Errorf("лист с названием %q не найден: %w", sheetName, apperrors.ErrSheetNotFound)
+	}
+	return sh.id, nil
+}
+
+func (m *MemoryBackend) WriteValues(spreadsheetID string, data []*sheets.ValueRange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return err
+	}
+
+	// Сначала проверяем все диапазоны, чтобы запись была атомарной, как в batchUpdate
+	type write struct {
+		sh *sheet
+		r  a1Notation.Range
+		vr *sheets.ValueRange
+	}
+	writes := make([]write, 0, len(data))
+	for _, vr := range data {
+		r, err := a1Notation.Parse(vr.Range)
+		if err != nil {
+			return fmt.Errorf("Не удалось записать данные: %v", err)
+		}
+		sh := ss.sheet(r.Sheet)
+		if sh == nil {
+			return fmt.Errorf("Не удалось записать данные: Unable to parse range: %s: %w", vr.Range, apperrors.ErrSheetNotFound)
+		}
+		if r.EndRow == r.StartRow+1 && r.EndCol == r.StartCol+1 {
+			// Одиночная ячейка задаёт только начало записи, как в Google Sheets
+			r.EndRow, r.EndCol = a1Notation.Unbounded, a1Notation.Unbounded
+		}
+		for i, row := range vr.Values {
+			rowIdx := r.StartRow + i
+			lastCol := r.StartCol + len(row)
+			if r.EndRow != a1Notation.Unbounded && rowIdx >= r.EndRow || r.EndCol != a1Notation.Unbounded && lastCol > r.EndCol {
+				return fmt.Errorf("Не удалось записать данные: данные выходят за пределы диапазона %s", vr.Range)
+			}
+			if int64(rowIdx) >= sh.rows || int64(lastCol) > sh.cols {
+				return fmt.Errorf("Не удалось записать данные: Range (%s) exceeds grid limits. Max rows: %d, max columns: %d",
+					vr.Range, sh.rows, sh.cols)
+			}
+		}
+		writes = append(writes, write{sh: sh, r: r, vr: vr})
+	}
+
+	for _, w := range writes {
+		for i, row := range w.vr.Values {
+			for j, value := range row {
+				w.sh.set(w.r.StartRow+i, w.r.StartCol+j, toString(value))
+			}
+		}
+		// Пустая строка в USER_ENTERED очищает ячейку
+		w.sh.trim()
+	}
+	return nil
+}
+
+// AppendValues дописывает строки после последней непустой строки таблицы в столбцах диапазона rng.
+// Как INSERT_ROWS в Google, строки вставляются в сетку: строки листа ниже сдвигаются, сетка растёт
+func (m *MemoryBackend) AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return "", err
+	}
+	r, err := a1Notation.Parse(rng)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось дописать данные: %v", err)
+	}
+	sh := ss.sheet(r.Sheet)
+	if sh == nil {
+		return "", fmt.Errorf("Не удалось дописать данные: Unable to parse range: %s: %w", rng, apperrors.ErrSheetNotFound)
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if int64(r.StartCol+width) > sh.cols {
+		return "", fmt.Errorf("Не удалось дописать данные: Range (%s) exceeds grid limits. Max columns: %d", rng, sh.cols)
+	}
+
+	at := r.StartRow
+	for row := r.StartRow; row < len(sh.cells); row++ {
+		for col := r.StartCol; col < len(sh.cells[row]); col++ {
+			if r.EndCol != a1Notation.Unbounded && col >= r.EndCol {
+				break
+			}
+			if sh.cells[row][col] != "" {
+				at = row + 1
+				break
+			}
+		}
+	}
+	if at < len(sh.cells) {
+		tail := append(make([][]string, len(rows)), sh.cells[at:]...)
+		sh.cells = append(sh.cells[:at], tail...)
+	}
+	sh.rows += int64(len(rows))
+	for i, row := range rows {
+		for j, value := range row {
+			sh.set(at+i, r.StartCol+j, toString(value))
+		}
+	}
+	sh.trim()
+	return a1Notation.Range{
+		Sheet:    r.Sheet,
+		StartRow: at,
+		StartCol: r.StartCol,
+		EndRow:   at + len(rows),
+		EndCol:   r.StartCol + max(width, 1),
+	}.String(), nil
+}
+
+func (m *MemoryBackend) DeleteSheet(spreadsheetID, sheetName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return err
+	}
+	for i, sh := range ss.sheets {
+		if sh.title == sheetName {
+			if len(ss.sheets) == 1 {
+				return fmt.Errorf("не удалось удалить лист %s: нельзя удалить единственный лист таблицы", sheetName)
+			}
+			ss.sheets = append(ss.sheets[:i], ss.sheets[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("не удалось найти лист %s: лист с названием %q не найден: %w", sheetName, sheetName, apperrors.ErrSheetNotFound)
+}
+
+func (m *MemoryBackend) DeleteSpreadsheet(spreadsheetID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.get(spreadsheetID); err != nil {
+		return err
+	}
+	delete(m.spreadsheets, spreadsheetID)
+	return nil
+}
+
+func (m *MemoryBackend) ListSpreadsheets() ([]backend.SpreadsheetInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]backend.SpreadsheetInfo, 0, len(m.spreadsheets))
+	for _, ss := range m.spreadsheets {
+		result = append(result, backend.SpreadsheetInfo{ID: ss.id, Title: ss.title, Fiat: ss.fiat, Managed: ss.managed})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (m *MemoryBackend) ListSheets(spreadsheetID string) ([]backend.SheetInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backend.SheetInfo, 0, len(ss.sheets))
+	for _, sh := range ss.sheets {
+		result = append(result, backend.SheetInfo{
+			SheetID:     sh.id,
+			Title:       sh.title,
+			RowCount:    sh.rows,
+			ColumnCount: sh.cols,
+		})
+	}
+	return result, nil
+}
+
+// EnsureFolder возвращает ID папки по пути, создавая её при отсутствии
+func (m *MemoryBackend) EnsureFolder(path []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.Join(path, "/")
+	if id, ok := m.folders[key]; ok {
+		return id, nil
+	}
+	id := fmt.Sprintf("folder-%d", len(m.folders)+1)
+	m.folders[key] = id
+	return id, nil
+}
+
+func (m *MemoryBackend) MoveToFolder(spreadsheetID, folderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return err
+	}
+	ss.folderID = folderID
+	return nil
+}
+
+// FolderOf возвращает ID папки, в которой лежит таблица
+func (m *MemoryBackend) FolderOf(spreadsheetID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return "", err
+	}
+	return ss.folderID, nil
+}
+
+// ReadValues возвращает копию значений листа; пустые строки в конце отбрасываются.
+// Формулы возвращаются как есть, без вычисления
+func (m *MemoryBackend) ReadValues(spreadsheetID, sheetName string) ([][]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	sh := ss.sheet(sheetName)
+	if sh == nil {
+		return nil, fmt.Errorf("лист с названием %q не найден: %w", sheetName, apperrors.ErrSheetNotFound)
+	}
+	result := make([][]string, len(sh.cells))
+	for i, row := range sh.cells {
+		result[i] = append([]string(nil), row...)
+	}
+	return result, nil
+}
+
+// Permissions возвращает список адресов, которым выдан доступ к таблице
+func (m *MemoryBackend) Permissions(spreadsheetID string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss, err := m.get(spreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), ss.permissions...), nil
+}
+
+func (m *MemoryBackend) get(spreadsheetID string) (*spreadsheet, error) {
+	ss, ok := m.spreadsheets[spreadsheetID]
+	if !ok {
+		return nil, fmt.Errorf("таблица %s не найдена: %w", spreadsheetID, apperrors.ErrSpreadsheetNotFound)
+	}
+	return ss, nil
+}
+
+func (ss *spreadsheet) addSheet(title string) *sheet {
+	sh := &sheet{
+		id:    ss.nextSheetID,
+		title: title,
+		rows:  DefaultRowCount,
+		cols:  DefaultColumnCount,
+	}
+	ss.nextSheetID++
+	ss.sheets = append(ss.sheets, sh)
+	return sh
+}
+
+func (ss *spreadsheet) sheet(title string) *sheet {
+	for _, sh := range ss.sheets {
+		if sh.title == title {
+			return sh
+		}
+	}
+	return nil
+}
+
+func (ss *spreadsheet) sheetByID(id int64) *sheet {
+	for _, sh := range ss.sheets {
+		if sh.id == id {
+			return sh
+		}
+	}
+	return nil
+}
+
+// clone возвращает копию листа с другими ID и названием
+func (sh *sheet) clone(id int64, title string) *sheet {
+	copied := &sheet{
+		id:    id,
+		title: title,
+		rows:  sh.rows,
+		cols:  sh.cols,
+		cells: make([][]string, len(sh.cells)),
+	}
+	for i, row := range sh.cells {
+		copied.cells[i] = append([]string(nil), row...)
+	}
+	if sh.filter != nil {
+		filter := *sh.filter
+		filter.SheetId = id
+		copied.filter = &filter
+	}
+	return copied
+}
+
+func (sh *sheet) set(row, col int, value string) {
+	for len(sh.cells) <= row {
+		sh.cells = append(sh.cells, nil)
+	}
+	for len(sh.cells[row]) <= col {
+		sh.cells[row] = append(sh.cells[row], "")
+	}
+	sh.cells[row][col] = value
+}
+
+// clear очищает ячейки диапазона; части диапазона за пределами сетки игнорируются
+func (sh *sheet) clear(r a1Notation.Range) {
+	for row := r.StartRow; row < len(sh.cells); row++ {
+		if r.EndRow != a1Notation.Unbounded && row >= r.EndRow {
+			break
+		}
+		cells := sh.cells[row]
+		for col := r.StartCol; col < len(cells); col++ {
+			if r.EndCol != a1Notation.Unbounded && col >= r.EndCol {
+				break
+			}
+			cells[col] = ""
+		}
+	}
+	sh.trim()
+}
+
+// trim отбрасывает пустые ячейки в конце строк и пустые строки в конце листа
+func (sh *sheet) trim() {
+	for i, row := range sh.cells {
+		end := len(row)
+		for end > 0 && row[end-1] == "" {
+			end--
+		}
+		sh.cells[i] = row[:end]
+	}
+	end := len(sh.cells)
+	for end > 0 && len(sh.cells[end-1]) == 0 {
+		end--
+	}
+	sh.cells = sh.cells[:end]
+}
+
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,151 @@
+package recordingBackend
+
+import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/models"
+	"google.golang.org/api/sheets/v4"
+	"sync"
+	"time"
+)
+
+// RecordingBackend передаёт вызовы другому backend.SpreadsheetBackend и запоминает каждый из них.
+// Используется для отчёта о фоновых задачах и для подсчёта запросов в тестах
+type RecordingBackend struct {
+	backend backend.SpreadsheetBackend
+	calls   []models.BackendCall
+	mu      sync.Mutex
+}
+
+// New оборачивает хранилище журналом вызовов
+func New(b backend.SpreadsheetBackend) *RecordingBackend {
+	return &RecordingBackend{backend: b}
+}
+
+// Calls возвращает копию журнала вызовов
+func (r *RecordingBackend) Calls() []models.BackendCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.BackendCall{}, r.calls...)
+}
+
+func (r *RecordingBackend) record(method, spreadsheetID string, start time.Time, err error) {
+	call := models.BackendCall{
+		Method:        method,
+		SpreadsheetID: spreadsheetID,
+		StartedAt:     start,
+		Duration:      time.Since(start).Seconds(),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *RecordingBackend) CreateSpreadsheet(title string) (string, error) {
+	start := time.Now()
+	id, err := r.backend.CreateSpreadsheet(title)
+	r.record("CreateSpreadsheet", id, start, err)
+	return id, err
+}
+
+func (r *RecordingBackend) CopySpreadsheet(templateID, title string) (string, error) {
+	start := time.Now()
+	id, err := r.backend.CopySpreadsheet(templateID, title)
+	r.record("CopySpreadsheet", id, start, err)
+	return id, err
+}
+
+func (r *RecordingBackend) AddPermission(spreadsheetID string, emails []string) error {
+	start := time.Now()
+	err := r.backend.AddPermission(spreadsheetID, emails)
+	r.record("AddPermission", spreadsheetID, start, err)
+	return err
+}
+
+func (r *RecordingBackend) TagSpreadsheet(spreadsheetID, fiat string) error {
+	start := time.Now()
+	err := r.backend.TagSpreadsheet(spreadsheetID, fiat)
+	r.record("TagSpreadsheet", spreadsheetID, start, err)
+	return err
+}
+
+func (r *RecordingBackend) BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	start := time.Now()
+	replies, err := r.backend.BatchUpdate(spreadsheetID, requests)
+	r.record("BatchUpdate", spreadsheetID, start, err)
+	return replies, err
+}
+
+func (r *RecordingBackend) SheetIDByName(spreadsheetID, sheetName string) (int64, error) {
+	start := time.Now()
+	id, err := r.backend.SheetIDByName(spreadsheetID, sheetName)
+	r.record("SheetIDByName", spreadsheetID, start, err)
+	return id, err
+}
+
+func (r *RecordingBackend) WriteValues(spreadsheetID string, data []*sheets.ValueRange) error {
+	start := time.Now()
+	err := r.backend.WriteValues(spreadsheetID, data)
+	r.record("WriteValues", spreadsheetID, start, err)
+	return err
+}
+
+func (r *RecordingBackend) AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	start := time.Now()
+	updated, err := r.backend.AppendValues(spreadsheetID, rng, rows)
+	r.record("AppendValues", spreadsheetID, start, err)
+	return updated, err
+}
+
+func (r *RecordingBackend) DeleteSheet(spreadsheetID, sheetName string) error {
+	start := time.Now()
+	err := r.backend.DeleteSheet(spreadsheetID, sheetName)
+	r.record("DeleteSheet", spreadsheetID, start, err)
+	return err
+}
+
+func (r *RecordingBackend) DeleteSpreadsheet(spreadsheetID string) error {
+	start := time.Now()
+	err := r.backend.DeleteSpreadsheet(spreadsheetID)
+	r.record("DeleteSpreadsheet", spreadsheetID, start, err)
+	return err
+}
+
+func (r *RecordingBackend) ListSpreadsheets() ([]backend.SpreadsheetInfo, error) {
+	start := time.Now()
+	list, err := r.backend.ListSpreadsheets()
+	r.record("ListSpreadsheets", "", start, err)
+	return list, err
+}
+
+func (r *RecordingBackend) ReadValues(spreadsheetID, sheetName string) ([][]string, error) {
+	start := time.Now()
+	values, err := r.backend.ReadValues(spreadsheetID, sheetName)
+	r.record("ReadValues", spreadsheetID, start, err)
+	return values, err
+}
+
+func (r *RecordingBackend) ListSheets(spreadsheetID string) ([]backend.SheetInfo, error) {
+	start := time.Now()
+	list, err := r.backend.ListSheets(spreadsheetID)
+	r.record("ListSheets", spreadsheetID, start, err)
+	return list, err
+}
+
+func (r *RecordingBackend) EnsureFolder(path []string) (string, error) {
+	start := time.Now()
+	id, err := r.backend.EnsureFolder(path)
+	r.record("EnsureFolder", "", start, err)
+	return id, err
+}
+
+func (r *RecordingBackend) MoveToFolder(spreadsheetID, folderID string) error {
+	start := time.Now()
+	err := r.backend.MoveToFolder(spreadsheetID, folderID)
+	r.record("MoveToFolder", spreadsheetID, start, err)
+	return err
+}
@@ -1,11 +1,21 @@
 package cache
 
+import "GoogleSheetW/internal/models"
+
 type Cache interface {
 	GetIDbyFiat(key string) (string, error) // if "not found" return apperrors "not found"
+	GetAllIDs() (map[string]string, error)  // fiat -> ID таблицы
 	SetIDbyFiat(key string, value string) error
+	// GetOrCreateIDbyFiat возвращает ID таблицы или создаёт её через create, если ID нет.
+	// Для одной валюты create вызывается не более одного раза, даже при нескольких репликах
+	GetOrCreateIDbyFiat(fiat string, create func() (string, error)) (string, error)
 	IsSupInCashed(fiat, supName string) (bool, error)
+	GetSupsByFiat(fiat string) ([]string, error) // листы валюты; пустой список, если валюты нет
 	SetSupInCashed(fiat, supName string) error
 	AddToCash(idMap map[string]string) error
-	RemoveSupFromCashed(fiat, supName string) error
+	RemoveSupFromCashed(fiat, supName string) error // удаляет лист вместе с его SheetState
 	RemoveFiatFromCache(fiat string) error
+	// GetSheetState возвращает размер последней записи в лист; false, если он неизвестен
+	GetSheetState(fiat, sheetName string) (models.SheetState, bool, error)
+	SetSheetState(fiat, sheetName string, state models.SheetState) error
 }
@@ -0,0 +1,153 @@
+package fileCache
+
+import (
+	"GoogleSheetW/internal/cache/localCache"
+	"GoogleSheetW/internal/logger"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// saveDelay - сколько копятся изменения кэша перед записью снимка в файл
+const saveDelay = time.Second
+
+// FileCache реализует интерфейс Cache кэшем в памяти (localCache) с сохранением снимка в JSON-файл.
+// После перезапуска кэш читается из файла, и полный обход Google Drive не нужен.
+// Изменения записываются в файл не чаще раза в saveDelay; ID новой таблицы сохраняется сразу
+type FileCache struct {
+	*localCache.MapCache
+	path string
+
+	version atomic.Uint64 // номер последнего изменения кэша
+	saved   uint64        // номер изменения, которое уже есть в файле; под saveMu
+	dirty   chan struct{} // есть несохранённые изменения
+	done    chan struct{} // закрывается в Close
+	saveMu  sync.Mutex    // снимки записываются в файл по одному
+	closed  sync.Once
+}
+
+// New открывает кэш, загружая снимок из файла, если он существует
+func New(path string) (*FileCache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог кэша: %v", err)
+	}
+
+	var snap localCache.Snapshot
+	file, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("не удалось прочитать кэш %s: %v", path, err)
+	default:
+		if err := json.Unmarshal(file, &snap); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать кэш %s: %v", path, err)
+		}
+	}
+
+	c := &FileCache{
+		path:  path,
+		dirty: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	c.MapCache = localCache.New(snap, c.changed)
+	go c.saveLoop()
+	return c, nil
+}
+
+// GetOrCreateIDbyFiat возвращает ID таблицы, а если его нет - создаёт таблицу через create.
+// ID новой таблицы сохраняется в файл сразу: потеря его при сбое привела бы ко второй таблице для валюты
+func (c *FileCache) GetOrCreateIDbyFiat(fiat string, create func() (string, error)) (string, error) {
+	id, err := c.MapCache.GetOrCreateIDbyFiat(fiat, create)
+	if err != nil {
+		return "", err
+	}
+	if err := c.Flush(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Flush сразу записывает несохранённые изменения в файл
+func (c *FileCache) Flush() error {
+	return c.save()
+}
+
+// Close записывает несохранённые изменения и останавливает фоновое сохранение
+func (c *FileCache) Close() error {
+	c.closed.Do(func() { close(c.done) })
+	return c.Flush()
+}
+
+// changed отмечает, что кэш изменился
+func (c *FileCache) changed() {
+	c.version.Add(1)
+	select {
+	case c.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// saveLoop записывает снимок через saveDelay после первого несохранённого изменения
+func (c *FileCache) saveLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.dirty:
+		}
+
+		timer := time.NewTimer(saveDelay)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return // изменения запишет Close
+		case <-timer.C:
+		}
+		if err := c.save(); err != nil {
+			// Снимок нужен только для быстрого запуска: при ошибке кэш восстановится сверкой с Google
+			logger.Get().Errorw("Ошибка сохранения кэша в файл", "path", c.path, "error", err)
+		}
+	}
+}
+
+// save атомарно записывает снимок кэша, если с прошлой записи он изменился:
+// сначала во временный файл, затем переименование
+func (c *FileCache) save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	version := c.version.Load()
+	if version == c.saved {
+		return nil
+	}
+	data, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать кэш: %v", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл кэша: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать кэш: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось сохранить кэш: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть файл кэша: %v", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("не удалось заменить файл кэша: %v", err)
+	}
+	c.saved = version
+	return nil
+}
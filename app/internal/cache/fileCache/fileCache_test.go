@@ -0,0 +1,63 @@
+package fileCache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCacheSavesOnCloseAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := c.AddToCash(map[string]string{"USD": "sheet-usd"}); err != nil {
+		t.Fatalf("AddToCash: %v", err)
+	}
+	if err := c.SetSupInCashed("USD", "RAW"); err != nil {
+		t.Fatalf("SetSupInCashed: %v", err)
+	}
+	// Изменения копятся в памяти и не пишутся в файл при каждом вызове
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("файл кэша записан до истечения saveDelay: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer reopened.Close()
+	if id, err := reopened.GetIDbyFiat("USD"); err != nil || id != "sheet-usd" {
+		t.Fatalf("GetIDbyFiat = %q, %v", id, err)
+	}
+	if ok, _ := reopened.IsSupInCashed("USD", "RAW"); !ok {
+		t.Fatal("лист RAW не восстановлен из файла")
+	}
+}
+
+func TestFileCacheFlushesNewSpreadsheetImmediately(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	id, err := c.GetOrCreateIDbyFiat("EUR", func() (string, error) { return "sheet-eur", nil })
+	if err != nil || id != "sheet-eur" {
+		t.Fatalf("GetOrCreateIDbyFiat = %q, %v", id, err)
+	}
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer reopened.Close()
+	if id, err := reopened.GetIDbyFiat("EUR"); err != nil || id != "sheet-eur" {
+		t.Fatalf("ID новой таблицы не записан в файл сразу: %q, %v", id, err)
+	}
+}
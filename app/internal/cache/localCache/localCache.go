@@ -2,15 +2,31 @@ package localCache
 
 import (
 	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/keyLock"
+	"GoogleSheetW/internal/models"
 	"fmt"
+	"maps"
 	"sync"
 )
 
+// Snapshot - содержимое кэша, например для сохранения в файл
+type Snapshot struct {
+	FiatToID map[string]string          `json:"fiat_to_id"`
+	SupMap   map[string]map[string]bool `json:"sup_map"`
+
+	States map[string]map[string]models.SheetState `json:"sheet_states,omitempty"`
+}
+
 // MapCache реализует интерфейс Cache с использованием карт в памяти
 type MapCache struct {
 	fiatToID map[string]string          // ключ: fiat, значение: ID таблицы
 	supMap   map[string]map[string]bool // ключ1: fiat, ключ2: supName, значение: bool (наличие)
+	createMu keyLock.Locks              // не даёт создать таблицу для одной валюты дважды
 	mu       sync.RWMutex               // мьютекс для безопасности при конкурентном доступе
+
+	states map[string]map[string]models.SheetState // ключ1: fiat, ключ2: supName; размер последней записи
+
+	onChange func() // вызывается после каждого изменения кэша, вне блокировки
 }
 
 var (
@@ -21,14 +37,63 @@ var (
 // GetInstance возвращает единственный экземпляр кэша (синглтон)
 func GetInstance() *MapCache {
 	once.Do(func() {
-		instance = &MapCache{
-			fiatToID: make(map[string]string),
-			supMap:   make(map[string]map[string]bool),
-		}
+		instance = New(Snapshot{}, nil)
 	})
 	return instance
 }
 
+// New создаёт кэш с содержимым snap. onChange, если задан, вызывается после каждого изменения:
+// через него кэш сохраняется во внешнее хранилище
+func New(snap Snapshot, onChange func()) *MapCache {
+	c := &MapCache{
+		fiatToID: snap.FiatToID,
+		supMap:   snap.SupMap,
+		states:   snap.States,
+		onChange: onChange,
+	}
+	if c.fiatToID == nil {
+		c.fiatToID = make(map[string]string)
+	}
+	if c.supMap == nil {
+		c.supMap = make(map[string]map[string]bool)
+	}
+	if c.states == nil {
+		c.states = make(map[string]map[string]models.SheetState)
+	}
+	return c
+}
+
+// Snapshot возвращает копию содержимого кэша
+func (c *MapCache) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snap := Snapshot{
+		FiatToID: maps.Clone(c.fiatToID),
+		SupMap:   make(map[string]map[string]bool, len(c.supMap)),
+		States:   make(map[string]map[string]models.SheetState, len(c.states)),
+	}
+	for fiat, sups := range c.supMap {
+		snap.SupMap[fiat] = maps.Clone(sups)
+	}
+	for fiat, states := range c.states {
+		snap.States[fiat] = maps.Clone(states)
+	}
+	return snap
+}
+
+// update выполняет изменение под блокировкой и, если fn сообщила об изменении, вызывает onChange
+func (c *MapCache) update(fn func() (bool, error)) error {
+	c.mu.Lock()
+	changed, err := fn()
+	c.mu.Unlock()
+
+	if changed && c.onChange != nil {
+		c.onChange()
+	}
+	return err
+}
+
 // GetIDbyFiat возвращает ID таблицы по ключу fiat
 func (c *MapCache) GetIDbyFiat(key string) (string, error) {
 	c.mu.RLock()
@@ -41,13 +106,38 @@ func (c *MapCache) GetIDbyFiat(key string) (string, error) {
 	return id, nil
 }
 
+// GetAllIDs возвращает копию соответствия валют и ID таблиц
+func (c *MapCache) GetAllIDs() (map[string]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return maps.Clone(c.fiatToID), nil
+}
+
 // SetIDbyFiat устанавливает ID таблицы для ключа fiat
 func (c *MapCache) SetIDbyFiat(key string, value string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.update(func() (bool, error) {
+		c.fiatToID[key] = value
+		return true, nil
+	})
+}
 
-	c.fiatToID[key] = value
-	return nil
+// GetOrCreateIDbyFiat возвращает ID таблицы, а если его нет - создаёт таблицу через create
+func (c *MapCache) GetOrCreateIDbyFiat(fiat string, create func() (string, error)) (string, error) {
+	unlock := c.createMu.Lock(fiat)
+	defer unlock()
+
+	if id, err := c.GetIDbyFiat(fiat); err == nil {
+		return id, nil
+	}
+	id, err := create()
+	if err != nil {
+		return "", err
+	}
+	if err := c.SetIDbyFiat(fiat, id); err != nil {
+		return "", err
+	}
+	return id, nil
 }
 
 // IsSupInCashed проверяет, есть ли лист в кэше для указанной валюты
@@ -63,55 +153,89 @@ func (c *MapCache) IsSupInCashed(fiat, supName string) (bool, error) {
 	return supMapForFiat[supName], nil
 }
 
-// SetSupInCashed добавляет лист в кэш для указанной валюты
-func (c *MapCache) SetSupInCashed(fiat, supName string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// GetSupsByFiat возвращает листы, сохранённые в кэше для валюты
+func (c *MapCache) GetSupsByFiat(fiat string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if _, exists := c.supMap[fiat]; !exists {
-		c.supMap[fiat] = make(map[string]bool)
+	sups := make([]string, 0, len(c.supMap[fiat]))
+	for supName, exists := range c.supMap[fiat] {
+		if exists {
+			sups = append(sups, supName)
+		}
 	}
+	return sups, nil
+}
 
-	c.supMap[fiat][supName] = true
-	return nil
+// SetSupInCashed добавляет лист в кэш для указанной валюты
+func (c *MapCache) SetSupInCashed(fiat, supName string) error {
+	return c.update(func() (bool, error) {
+		if c.supMap[fiat][supName] {
+			return false, nil
+		}
+		if _, exists := c.supMap[fiat]; !exists {
+			c.supMap[fiat] = make(map[string]bool)
+		}
+
+		c.supMap[fiat][supName] = true
+		return true, nil
+	})
 }
 
 // RemoveSupFromCashed удаляет лист из кэша для указанной валюты
 func (c *MapCache) RemoveSupFromCashed(fiat, supName string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	supMapForFiat, exists := c.supMap[fiat]
-	if !exists {
-		return fmt.Errorf("валюта %s не найдена в кэше", fiat)
-	}
+	return c.update(func() (bool, error) {
+		supMapForFiat, exists := c.supMap[fiat]
+		if !exists {
+			return false, fmt.Errorf("валюта %s не найдена в кэше", fiat)
+		}
 
-	delete(supMapForFiat, supName)
-	return nil
+		delete(supMapForFiat, supName)
+		delete(c.states[fiat], supName)
+		return true, nil
+	})
 }
 
 // RemoveFiatFromCache удаляет всю валюту и связанные с ней листы из кэша
 func (c *MapCache) RemoveFiatFromCache(fiat string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	delete(c.fiatToID, fiat)
-	delete(c.supMap, fiat)
-	return nil
+	return c.update(func() (bool, error) {
+		delete(c.fiatToID, fiat)
+		delete(c.supMap, fiat)
+		delete(c.states, fiat)
+		return true, nil
+	})
 }
 
 // AddToCash добавляет в кэш сразу несколько таблиц
 func (c *MapCache) AddToCash(idMap map[string]string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	for fiat, id := range idMap {
-		c.fiatToID[fiat] = id
-		// Инициализируем карту для листов, если её ещё нет
-		if _, exists := c.supMap[fiat]; !exists {
-			c.supMap[fiat] = make(map[string]bool)
+	return c.update(func() (bool, error) {
+		for fiat, id := range idMap {
+			c.fiatToID[fiat] = id
+			// Инициализируем карту для листов, если её ещё нет
+			if _, exists := c.supMap[fiat]; !exists {
+				c.supMap[fiat] = make(map[string]bool)
+			}
 		}
-	}
+		return len(idMap) > 0, nil
+	})
+}
+
+// GetSheetState возвращает размер последней записи в лист
+func (c *MapCache) GetSheetState(fiat, sheetName string) (models.SheetState, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	return nil
+	state, exists := c.states[fiat][sheetName]
+	return state, exists, nil
+}
+
+// SetSheetState сохраняет размер последней записи в лист
+func (c *MapCache) SetSheetState(fiat, sheetName string, state models.SheetState) error {
+	return c.update(func() (bool, error) {
+		if _, exists := c.states[fiat]; !exists {
+			c.states[fiat] = make(map[string]models.SheetState)
+		}
+		c.states[fiat][sheetName] = state
+		return true, nil
+	})
 }
@@ -0,0 +1,309 @@
+package redisCache
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/logger"
+	"GoogleSheetW/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Options настройки подключения и блокировки создания таблиц
+type Options struct {
+	Addr      string
+	Password  string
+	DB        int
+	Prefix    string        // префикс ключей, чтобы несколько окружений могли делить один сервер
+	LockTTL   time.Duration // время жизни блокировки создания таблицы
+	LockRetry time.Duration // интервал проверки, пока таблицу создаёт другая реплика
+}
+
+// releaseLock удаляет блокировку, только если она принадлежит этой реплике
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewLock продлевает блокировку, только если она всё ещё принадлежит этой реплике
+var renewLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// RedisCache реализует интерфейс Cache поверх сервера с протоколом Redis, общего для всех реплик.
+// Ключи: {prefix}:fiats - хэш fiat -> ID таблицы, {prefix}:sups:{fiat} - множество листов валюты,
+// {prefix}:lock:{fiat} - блокировка создания таблицы, {prefix}:states:{fiat} - хэш лист -> SheetState в JSON
+type RedisCache struct {
+	client *redis.Client
+	opts   Options
+}
+
+// New подключается к серверу и проверяет соединение
+func New(ctx context.Context, opts Options) (*RedisCache, error) {
+	if opts.Prefix == "" {
+		opts.Prefix = "gsw"
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = time.Minute
+	}
+	if opts.LockRetry <= 0 {
+		opts.LockRetry = 200 * time.Millisecond
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к Redis %s: %v", opts.Addr, err)
+	}
+	return &RedisCache{client: client, opts: opts}, nil
+}
+
+func (c *RedisCache) fiatsKey() string {
+	return c.opts.Prefix + ":fiats"
+}
+
+func (c *RedisCache) supsKey(fiat string) string {
+	return c.opts.Prefix + ":sups:" + fiat
+}
+
+func (c *RedisCache) lockKey(fiat string) string {
+	return c.opts.Prefix + ":lock:" + fiat
+}
+
+func (c *RedisCache) statesKey(fiat string) string {
+	return c.opts.Prefix + ":states:" + fiat
+}
+
+// GetIDbyFiat возвращает ID таблицы по ключу fiat
+func (c *RedisCache) GetIDbyFiat(key string) (string, error) {
+	id, err := c.client.HGet(context.Background(), c.fiatsKey(), key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", apperrors.ErrCacheNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения ID таблицы %s из Redis: %v", key, err)
+	}
+	return id, nil
+}
+
+// GetAllIDs возвращает соответствие валют и ID таблиц
+func (c *RedisCache) GetAllIDs() (map[string]string, error) {
+	ids, err := c.client.HGetAll(context.Background(), c.fiatsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ID таблиц из Redis: %v", err)
+	}
+	return ids, nil
+}
+
+// SetIDbyFiat устанавливает ID таблицы для ключа fiat
+func (c *RedisCache) SetIDbyFiat(key string, value string) error {
+	if err := c.client.HSet(context.Background(), c.fiatsKey(), key, value).Err(); err != nil {
+		return fmt.Errorf("ошибка записи ID таблицы %s в Redis: %v", key, err)
+	}
+	return nil
+}
+
+// GetOrCreateIDbyFiat возвращает ID таблицы, а если его нет - создаёт таблицу через create.
+// Создание защищено блокировкой в Redis, поэтому таблицу для валюты создаёт только одна реплика;
+// остальные дожидаются появления ID
+func (c *RedisCache) GetOrCreateIDbyFiat(fiat string, create func() (string, error)) (string, error) {
+	ctx := context.Background()
+	for {
+		id, err := c.GetIDbyFiat(fiat)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, apperrors.ErrCacheNotFound) {
+			return "", err
+		}
+
+		token := uuid.NewString()
+		locked, err := c.client.SetNX(ctx, c.lockKey(fiat), token, c.opts.LockTTL).Result()
+		if err != nil {
+			return "", fmt.Errorf("ошибка блокировки создания таблицы %s в Redis: %v", fiat, err)
+		}
+		if !locked {
+			// Таблицу создаёт другая реплика
+			time.Sleep(c.opts.LockRetry)
+			continue
+		}
+
+		return c.createLocked(ctx, fiat, token, create)
+	}
+}
+
+// createLocked создаёт таблицу под полученной блокировкой и снимает её. Пока create выполняется,
+// блокировка продлевается: создание с повторами запросов к Google может идти дольше LockTTL
+func (c *RedisCache) createLocked(ctx context.Context, fiat, token string, create func() (string, error)) (string, error) {
+	defer releaseLock.Run(ctx, c.client, []string{c.lockKey(fiat)}, token)
+	stopRenew := c.keepLock(fiat, token)
+	defer stopRenew()
+
+	// ID мог появиться, пока блокировку держала другая реплика
+	id, err := c.GetIDbyFiat(fiat)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, apperrors.ErrCacheNotFound) {
+		return "", err
+	}
+
+	id, err = create()
+	if err != nil {
+		return "", err
+	}
+	// HSETNX: если блокировка всё же была потеряна и другая реплика успела записать свой ID,
+	// он не перезаписывается, и все реплики пишут в одну таблицу
+	set, err := c.client.HSetNX(ctx, c.fiatsKey(), fiat, id).Result()
+	if err != nil {
+		return "", fmt.Errorf("ошибка записи ID таблицы %s в Redis: %v", fiat, err)
+	}
+	if !set {
+		existing, err := c.GetIDbyFiat(fiat)
+		if err != nil {
+			return "", err
+		}
+		logger.Get().Warnw("Таблица для валюты уже создана другой репликой, используется её таблица",
+			"fiat", fiat,
+			"spreadsheetID", existing,
+			"duplicate_id", id)
+		return existing, nil
+	}
+	return id, nil
+}
+
+// keepLock продлевает блокировку создания таблицы каждые LockTTL/3, пока не вызвана возвращённая функция
+func (c *RedisCache) keepLock(fiat, token string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(max(c.opts.LockTTL/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := renewLock.Run(context.Background(), c.client, []string{c.lockKey(fiat)},
+					token, c.opts.LockTTL.Milliseconds()).Int()
+				if err != nil || renewed == 0 {
+					logger.Get().Warnw("Не удалось продлить блокировку создания таблицы", "fiat", fiat, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// IsSupInCashed проверяет, есть ли лист в кэше для указанной валюты
+func (c *RedisCache) IsSupInCashed(fiat, supName string) (bool, error) {
+	ok, err := c.client.SIsMember(context.Background(), c.supsKey(fiat), supName).Result()
+	if err != nil {
+		return false, fmt.Errorf("ошибка чтения листа %s %s из Redis: %v", fiat, supName, err)
+	}
+	return ok, nil
+}
+
+// GetSupsByFiat возвращает листы, сохранённые в кэше для валюты
+func (c *RedisCache) GetSupsByFiat(fiat string) ([]string, error) {
+	sups, err := c.client.SMembers(context.Background(), c.supsKey(fiat)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения листов %s из Redis: %v", fiat, err)
+	}
+	return sups, nil
+}
+
+// SetSupInCashed добавляет лист в кэш для указанной валюты
+func (c *RedisCache) SetSupInCashed(fiat, supName string) error {
+	if err := c.client.SAdd(context.Background(), c.supsKey(fiat), supName).Err(); err != nil {
+		return fmt.Errorf("ошибка записи листа %s %s в Redis: %v", fiat, supName, err)
+	}
+	return nil
+}
+
+// RemoveSupFromCashed удаляет лист из кэша для указанной валюты
+func (c *RedisCache) RemoveSupFromCashed(fiat, supName string) error {
+	ctx := context.Background()
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SRem(ctx, c.supsKey(fiat), supName)
+		pipe.HDel(ctx, c.statesKey(fiat), supName)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка удаления листа %s %s из Redis: %v", fiat, supName, err)
+	}
+	return nil
+}
+
+// RemoveFiatFromCache удаляет всю валюту и связанные с ней листы из кэша
+func (c *RedisCache) RemoveFiatFromCache(fiat string) error {
+	ctx := context.Background()
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, c.fiatsKey(), fiat)
+		pipe.Del(ctx, c.supsKey(fiat))
+		pipe.Del(ctx, c.statesKey(fiat))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка удаления валюты %s из Redis: %v", fiat, err)
+	}
+	return nil
+}
+
+// AddToCash добавляет в кэш сразу несколько таблиц
+func (c *RedisCache) AddToCash(idMap map[string]string) error {
+	if len(idMap) == 0 {
+		return nil
+	}
+	if err := c.client.HSet(context.Background(), c.fiatsKey(), idMap).Err(); err != nil {
+		return fmt.Errorf("ошибка записи ID таблиц в Redis: %v", err)
+	}
+	return nil
+}
+
+// GetSheetState возвращает размер последней записи в лист
+func (c *RedisCache) GetSheetState(fiat, sheetName string) (models.SheetState, bool, error) {
+	var state models.SheetState
+	data, err := c.client.HGet(context.Background(), c.statesKey(fiat), sheetName).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, fmt.Errorf("ошибка чтения состояния листа %s %s из Redis: %v", fiat, sheetName, err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, fmt.Errorf("ошибка разбора состояния листа %s %s: %v", fiat, sheetName, err)
+	}
+	return state, true, nil
+}
+
+// SetSheetState сохраняет размер последней записи в лист
+func (c *RedisCache) SetSheetState(fiat, sheetName string, state models.SheetState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации состояния листа %s %s: %v", fiat, sheetName, err)
+	}
+	if err := c.client.HSet(context.Background(), c.statesKey(fiat), sheetName, data).Err(); err != nil {
+		return fmt.Errorf("ошибка записи состояния листа %s %s в Redis: %v", fiat, sheetName, err)
+	}
+	return nil
+}
+
+// Close закрывает соединение с сервером
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,100 @@
+package redisCache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, m *miniredis.Miniredis, lockTTL time.Duration) *RedisCache {
+	t.Helper()
+	c, err := New(context.Background(), Options{Addr: m.Addr(), LockTTL: lockTTL, LockRetry: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// Создание таблицы идёт дольше LockTTL: блокировка продлевается, и вторая реплика не создаёт дубликат
+func TestGetOrCreateRenewsLockWhileCreating(t *testing.T) {
+	m := miniredis.RunT(t)
+	const lockTTL = 300 * time.Millisecond
+	first := newTestCache(t, m, lockTTL)
+	second := newTestCache(t, m, lockTTL)
+
+	var creates atomic.Int32
+	release := make(chan struct{})
+	create := func() (string, error) {
+		creates.Add(1)
+		<-release
+		return "sheet-usd", nil
+	}
+
+	firstID := make(chan string, 1)
+	go func() {
+		id, err := first.GetOrCreateIDbyFiat("USD", create)
+		if err != nil {
+			t.Errorf("first GetOrCreateIDbyFiat: %v", err)
+		}
+		firstID <- id
+	}()
+	for !m.Exists(first.lockKey("USD")) {
+		time.Sleep(time.Millisecond)
+	}
+
+	secondID := make(chan string, 1)
+	go func() {
+		id, err := second.GetOrCreateIDbyFiat("USD", create)
+		if err != nil {
+			t.Errorf("second GetOrCreateIDbyFiat: %v", err)
+		}
+		secondID <- id
+	}()
+
+	// Время в miniredis идёт только через FastForward: без продления блокировка истекла бы
+	// уже на втором шаге
+	for i := 0; i < 5; i++ {
+		time.Sleep(lockTTL / 2)
+		m.FastForward(2 * lockTTL / 3)
+		if !m.Exists(first.lockKey("USD")) {
+			t.Fatalf("блокировка истекла на шаге %d, пока таблица создаётся", i)
+		}
+	}
+	close(release)
+
+	if id := <-firstID; id != "sheet-usd" {
+		t.Fatalf("first id = %q", id)
+	}
+	if id := <-secondID; id != "sheet-usd" {
+		t.Fatalf("second id = %q", id)
+	}
+	if n := creates.Load(); n != 1 {
+		t.Fatalf("create вызван %d раз, want 1", n)
+	}
+	if m.Exists(first.lockKey("USD")) {
+		t.Fatal("блокировка не снята после создания")
+	}
+}
+
+// Если блокировка всё же истекла и другая реплика записала свой ID, он не перезаписывается
+func TestGetOrCreateKeepsOtherReplicaIDAfterLockLoss(t *testing.T) {
+	m := miniredis.RunT(t)
+	c := newTestCache(t, m, time.Minute)
+
+	id, err := c.GetOrCreateIDbyFiat("EUR", func() (string, error) {
+		m.Del(c.lockKey("EUR"))
+		m.HSet(c.fiatsKey(), "EUR", "sheet-other")
+		return "sheet-mine", nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrCreateIDbyFiat: %v", err)
+	}
+	if id != "sheet-other" {
+		t.Fatalf("id = %q, want sheet-other", id)
+	}
+	if stored := m.HGet(c.fiatsKey(), "EUR"); stored != "sheet-other" {
+		t.Fatalf("в Redis %q, want sheet-other", stored)
+	}
+}
@@ -2,12 +2,12 @@ package config
 
 import (
 	"encoding/json"
-	"flag"
 	"github.com/ilyakaznacheev/cleanenv"
 	"log/slog"
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Config struct {
@@ -19,8 +19,68 @@ type AppConfig struct {
 	Port           int    `yaml:"port" env:"PORT" env-default:"8888"`
 	LogLevel       string `yaml:"log_level" env:"LOG_LEVEL" env-default:"debug"`
 	GoogleJsonPath string `yaml:"google_json_path" env:"GOOGLE_JSON_PATH" env-default:"./google.json"`
+	GoogleEndpoint string `yaml:"google_endpoint" env:"GOOGLE_ENDPOINT"` // адрес замены Google API, например для интеграционных тестов
+	DriveFolderID  string `yaml:"drive_folder_id" env:"DRIVE_FOLDER_ID"` // корневая папка Drive для таблиц сервиса; пусто - весь Drive
 	EmailsPath     string `yaml:"emails_path" env:"EMAILS_PATH"`
 	EmailsList     string `yaml:"emails_list" env:"EMAILS_LIST"`
+	Backend        string `yaml:"backend" env:"BACKEND" env-default:"google"` // google или memory (без обращения к Google)
+
+	// Подпапки для новых таблиц внутри DRIVE_FOLDER_ID: шаблон с {year}, {month}, {fiat}, {region},
+	// например "{region}/{year}", и регионы валют в виде "USD:americas,EUR:europe"
+	DriveFolderTemplate string            `yaml:"drive_folder_template" env:"DRIVE_FOLDER_TEMPLATE"`
+	FiatRegions         map[string]string `yaml:"fiat_regions" env:"FIAT_REGIONS"`
+
+	// Таблица-шаблон: новые таблицы валют создаются её копией, а листы супов - копией листа TEMPLATE_SHEET_NAME.
+	// Пусто - таблицы и листы создаются пустыми, оформление задаётся в коде
+	TemplateSpreadsheetID string `yaml:"template_spreadsheet_id" env:"TEMPLATE_SPREADSHEET_ID"`
+	TemplateSheetName     string `yaml:"template_sheet_name" env:"TEMPLATE_SHEET_NAME" env-default:"TEMPLATE"`
+
+	// Файл раскладки листов (YAML или JSON): где и какие поля записываются. Пусто - встроенная раскладка
+	LayoutPath string `yaml:"layout_path" env:"LAYOUT_PATH"`
+	// Строк данных на листе RAW; остальные переносятся на RAW_2, RAW_3 и т.д. 0 - без ограничения
+	RawMaxRows int `yaml:"raw_max_rows" env:"RAW_MAX_ROWS" env-default:"0"`
+	// Окно дозаписи RAW (PUT /api/sheets/{fiat}/raw?mode=append): сколько последних строк хранить и строки
+	// не старше какого возраста по столбцу времени RAW_TIME_COLUMN (с 0). 0 - без ограничения
+	RawWindowRows int           `yaml:"raw_window_rows" env:"RAW_WINDOW_ROWS" env-default:"0"`
+	RawWindowAge  time.Duration `yaml:"raw_window_age" env:"RAW_WINDOW_AGE" env-default:"0"`
+	RawTimeColumn int           `yaml:"raw_time_column" env:"RAW_TIME_COLUMN" env-default:"0"`
+
+	// Повторы запросов к Google API при превышении квоты и временных ошибках
+	RetryMaxAttempts  int           `yaml:"retry_max_attempts" env:"RETRY_MAX_ATTEMPTS" env-default:"5"`
+	RetryInitialDelay time.Duration `yaml:"retry_initial_delay" env:"RETRY_INITIAL_DELAY" env-default:"1s"`
+	RetryMaxDelay     time.Duration `yaml:"retry_max_delay" env:"RETRY_MAX_DELAY" env-default:"32s"`
+
+	// Ограничение частоты запросов к Google API, общее для всех валют; 0 отключает ограничение
+	ReadRequestsPerMinute  int           `yaml:"read_requests_per_minute" env:"READ_REQUESTS_PER_MINUTE" env-default:"60"`
+	WriteRequestsPerMinute int           `yaml:"write_requests_per_minute" env:"WRITE_REQUESTS_PER_MINUTE" env-default:"60"`
+	RateLimitBurst         int           `yaml:"rate_limit_burst" env:"RATE_LIMIT_BURST" env-default:"10"`
+	RequestQueueSize       int           `yaml:"request_queue_size" env:"REQUEST_QUEUE_SIZE" env-default:"100"`
+	RequestQueueMaxWait    time.Duration `yaml:"request_queue_max_wait" env:"REQUEST_QUEUE_MAX_WAIT" env-default:"5m"`
+
+	// Фоновые задачи записи (POST /api/sheets/set-data?async=true)
+	JobWorkers   int           `yaml:"job_workers" env:"JOB_WORKERS" env-default:"4"`
+	JobQueueSize int           `yaml:"job_queue_size" env:"JOB_QUEUE_SIZE" env-default:"100"`
+	JobRetention time.Duration `yaml:"job_retention" env:"JOB_RETENTION" env-default:"1h"`
+	// Сколько валют пакетного запроса (POST /api/sheets/bulk) записывается одновременно
+	BulkWorkers int `yaml:"bulk_workers" env:"BULK_WORKERS" env-default:"4"`
+
+	// Кэш ID таблиц и листов: memory, file (снимок в CACHE_PATH переживает перезапуск)
+	// или redis (общий для нескольких реплик)
+	CacheType            string        `yaml:"cache_type" env:"CACHE_TYPE" env-default:"memory"`
+	CachePath            string        `yaml:"cache_path" env:"CACHE_PATH" env-default:"data/cache.json"`
+	CacheRefreshInterval time.Duration `yaml:"cache_refresh_interval" env:"CACHE_REFRESH_INTERVAL" env-default:"1h"` // интервал сверки кэша с Google Drive
+	// Таблицы без метки сервиса (созданные до появления меток) присваиваются по названию
+	AdoptUntaggedSpreadsheets bool `yaml:"adopt_untagged_spreadsheets" env:"ADOPT_UNTAGGED_SPREADSHEETS" env-default:"true"`
+
+	// Подключение к Redis для CACHE_TYPE=redis
+	RedisAddr     string `yaml:"redis_addr" env:"REDIS_ADDR" env-default:"localhost:6379"`
+	RedisPassword string `yaml:"redis_password" env:"REDIS_PASSWORD"`
+	RedisDB       int    `yaml:"redis_db" env:"REDIS_DB" env-default:"0"`
+	RedisPrefix   string `yaml:"redis_prefix" env:"REDIS_PREFIX" env-default:"gsw"`
+
+	// Журнал исходящих данных на диске; пустой путь отключает журнал
+	OutboxPath           string        `yaml:"outbox_path" env:"OUTBOX_PATH"` // пусто - журнал отключён
+	OutboxReplayInterval time.Duration `yaml:"outbox_replay_interval" env:"OUTBOX_REPLAY_INTERVAL" env-default:"30s"`
 }
 
 type MetricsConfig struct {
@@ -29,36 +89,37 @@ type MetricsConfig struct {
 	Port    int    `yaml:"port" env:"METRICS_PORT" env-default:"8888"`
 }
 
-const (
-	flagConfigPathName = "config"
-	envConfigPathName  = "CONFIG_PATH"
-)
+const envConfigPathName = "CONFIG_PATH"
 
 var (
-	instance *Config
-	once     sync.Once
+	instance   *Config
+	once       sync.Once
+	configPath string
 )
 
+// SetPath задаёт путь к файлу конфигурации (флаг -config); вызывается из main до первого GetConfig.
+// Переменная окружения CONFIG_PATH имеет приоритет
+func SetPath(path string) {
+	configPath = path
+}
+
 func GetConfig() *Config {
 	once.Do(func() {
-		var configPath string
-		flag.StringVar(&configPath, flagConfigPathName, "", "path to the config file")
-		flag.Parse()
-
-		if path, ok := os.LookupEnv(envConfigPathName); ok {
-			configPath = path
+		path := configPath
+		if envPath, ok := os.LookupEnv(envConfigPathName); ok {
+			path = envPath
 		}
 
 		instance = &Config{}
 
-		if configPath != "" {
-			if readErr := cleanenv.ReadConfig(configPath, instance); readErr != nil {
+		if path != "" {
+			if readErr := cleanenv.ReadConfig(path, instance); readErr != nil {
 				description, descrErr := cleanenv.GetDescription(instance, nil)
 				if descrErr != nil {
 					panic(descrErr)
 				}
 				slog.Info(description)
-				slog.Error("failed to read config", slog.String("apperrors", readErr.Error()), slog.String("path", configPath))
+				slog.Error("failed to read config", slog.String("apperrors", readErr.Error()), slog.String("path", path))
 				os.Exit(1)
 			}
 		} else {
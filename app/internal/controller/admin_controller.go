@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+)
+
+// GetOutbox возвращает данные, сохранённые в журнале и ещё не отправленные в Google
+// URL: GET /admin/outbox
+func (sc *SheetsController) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	pending := sc.sheetsControl.PendingOutbox()
+	sc.sendSuccessResponse(w, "Неотправленные данные", map[string]interface{}{
+		"count":   len(pending),
+		"entries": pending,
+	})
+}
+
+// ReconcileCache запускает сверку кэша с хранилищем и возвращает отчёт об изменениях
+// URL: POST /admin/cache/reconcile
+func (sc *SheetsController) ReconcileCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	report, err := sc.sheetsControl.Reconcile()
+	if err != nil {
+		sc.log.Errorw("Ошибка сверки кэша", "error", err)
+		sc.sendErrorResponse(w, http.StatusInternalServerError, "Ошибка сверки кэша")
+		return
+	}
+
+	sc.sendSuccessResponse(w, "Кэш сверен с хранилищем", report)
+}
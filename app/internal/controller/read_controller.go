@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ListSpreadsheets возвращает таблицы всех валют с их листами из кэша (?refresh=true - после сверки с Google)
+// URL: GET /api/sheets
+func (sc *SheetsController) ListSpreadsheets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	spreadsheets, err := sc.sheetsControl.Spreadsheets(r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		sc.log.Errorw("Ошибка получения списка таблиц", "error", err)
+		sc.sendReadError(w, err)
+		return
+	}
+	sc.sendSuccessResponse(w, "Таблицы валют", spreadsheets)
+}
+
+// GetSpreadsheet возвращает таблицу валюты и её листы из кэша (?refresh=true - листы перечитываются из Google)
+// URL: GET /api/sheets/{fiat}
+func (sc *SheetsController) GetSpreadsheet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sheets/")
+	if path == "" || strings.Contains(path, "/") {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/sheets/{fiat}")
+		return
+	}
+	fiat, err := url.QueryUnescape(path)
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр fiat")
+		return
+	}
+
+	spreadsheet, err := sc.sheetsControl.Spreadsheet(fiat, r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		sc.log.Errorw("Ошибка получения таблицы", "error", err, "fiat", fiat)
+		sc.sendReadError(w, err)
+		return
+	}
+	sc.sendSuccessResponse(w, "Таблица валюты", spreadsheet)
+}
+
+// GetSheet читает лист супа и возвращает его данные в формате запроса set-data
+// URL: GET /api/sheets/{fiat}/sheet/{sheetName}
+func (sc *SheetsController) GetSheet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sheets/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[1] != "sheet" || parts[2] == "" {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/sheets/{fiat}/sheet/{sheetName}")
+		return
+	}
+	fiat, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр fiat")
+		return
+	}
+	sheetName, err := url.QueryUnescape(parts[2])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр sheetName")
+		return
+	}
+
+	soup, err := sc.sheetsControl.ReadSoup(fiat, sheetName)
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения листа", "error", err, "fiat", fiat, "sheetName", sheetName)
+		sc.sendReadError(w, err)
+		return
+	}
+	sc.sendSuccessResponse(w, "Данные листа", soup)
+}
+
+// GetRaw читает листы RAW валюты (вместе с RAW_2, RAW_3...)
+// URL: GET /api/sheets/{fiat}/raw
+func (sc *SheetsController) GetRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sheets/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "raw" || parts[0] == "" {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/sheets/{fiat}/raw")
+		return
+	}
+	fiat, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр fiat")
+		return
+	}
+
+	raw, err := sc.sheetsControl.ReadRaw(fiat)
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения листа RAW", "error", err, "fiat", fiat)
+		sc.sendReadError(w, err)
+		return
+	}
+	sc.sendSuccessResponse(w, "Данные листа RAW", raw)
+}
+
+// sendReadError отвечает 404, если таблицы или листа нет, и 400 на неверный запрос
+func (sc *SheetsController) sendReadError(w http.ResponseWriter, err error) {
+	var validationErr *apperrors.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		sc.sendErrorResponse(w, http.StatusBadRequest, validationErr.Msg)
+	case errors.Is(err, apperrors.ErrSpreadsheetNotFound):
+		sc.sendErrorResponse(w, http.StatusNotFound, "Таблица не найдена")
+	case errors.Is(err, apperrors.ErrSheetNotFound):
+		sc.sendErrorResponse(w, http.StatusNotFound, "Лист не найден")
+	default:
+		sc.sendErrorResponse(w, http.StatusInternalServerError, "Ошибка чтения данных")
+	}
+}
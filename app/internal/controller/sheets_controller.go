@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"GoogleSheetW/internal/apperrors"
 	"GoogleSheetW/internal/logger"
 	"GoogleSheetW/internal/models"
 	"GoogleSheetW/internal/services/sheetsControl"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go.uber.org/zap"
 	"net/http"
 	"net/url"
@@ -37,13 +40,149 @@ func (sc *SheetsController) SetSheetData(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := sc.sheetsControl.SetSheetData(req.SheetData); err != nil {
-		sc.log.Errorw("Ошибка установки данных в таблицу", "error", err)
-		sc.sendErrorResponse(w, http.StatusInternalServerError, "Ошибка обработки данных")
+	if r.URL.Query().Get("async") == "true" {
+		sc.submitSheetData(w, req.SheetData)
 		return
 	}
 
-	sc.sendSuccessResponse(w, "Данные успешно установлены", nil)
+	outcome, err := sc.sheetsControl.SetSheetData(req.SheetData)
+	sc.sendWriteResult(w, req.SheetData.Fiat, outcome, err)
+}
+
+// SetSheetDataBulk обрабатывает запрос на установку данных нескольких валют. Валюты записываются параллельно,
+// ошибка одной валюты не прерывает остальные: ответ содержит результат для каждой
+// URL: POST /api/sheets/bulk
+func (sc *SheetsController) SetSheetDataBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	var req models.BulkSetSheetDataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sc.log.Errorw("Ошибка декодирования JSON", "error", err)
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+	if len(req.SheetData) == 0 {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Список sheet_data пуст")
+		return
+	}
+
+	response := models.BulkSetSheetDataResponse{Results: sc.sheetsControl.SetSheetDataBulk(req.SheetData)}
+	for _, result := range response.Results {
+		if result.Status == string(sheetsControl.OutcomeFailed) {
+			response.Failed++
+			sc.log.Errorw("Ошибка установки данных в таблицу", "fiat", result.Fiat, "error", result.Error)
+		}
+	}
+
+	message := "Данные успешно установлены"
+	if response.Failed > 0 {
+		message = fmt.Sprintf("Не удалось записать данные %d из %d валют", response.Failed, len(response.Results))
+	}
+	sc.sendJSONResponse(w, http.StatusOK, models.APIResponse{
+		Success: response.Failed == 0,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// sendWriteResult отправляет ответ на запись данных валюты: 200 - записано или заменено более новыми данными,
+// 202 - запись отложена до повтора из журнала, 500 - ошибка с шагом, на котором запись прервалась
+func (sc *SheetsController) sendWriteResult(w http.ResponseWriter, fiat string, outcome sheetsControl.WriteOutcome, err error) {
+	if outcome == sheetsControl.OutcomeDeferred {
+		// Данные уже на диске и будут отправлены повторно, поэтому клиенту не нужно их пересылать
+		sc.log.Warnw("Запись отложена", "fiat", fiat, "error", err)
+		details := writeFailure(fiat, err)
+		details["status"] = outcome
+		sc.sendJSONResponse(w, http.StatusAccepted, models.APIResponse{
+			Success: true,
+			Message: "Данные сохранены и будут отправлены повторно",
+			Data:    details,
+		})
+		return
+	}
+	if err != nil {
+		sc.log.Errorw("Ошибка установки данных в таблицу", "fiat", fiat, "error", err)
+		sc.sendJSONResponse(w, http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Ошибка",
+			Data:    writeFailure(fiat, err),
+			Error:   "Ошибка обработки данных",
+		})
+		return
+	}
+
+	message := "Данные успешно установлены"
+	if outcome == sheetsControl.OutcomeSuperseded {
+		message = "Данные заменены более новыми и не записывались"
+	}
+	sc.sendSuccessResponse(w, message, map[string]interface{}{
+		"fiat":   fiat,
+		"status": outcome,
+	})
+}
+
+// writeFailure возвращает подробности ошибки записи: шаг, на котором запись прервалась,
+// и отменены ли изменения, сделанные до него
+func writeFailure(fiat string, err error) map[string]interface{} {
+	details := map[string]interface{}{"fiat": fiat}
+	var stepErr *apperrors.StepError
+	if errors.As(err, &stepErr) {
+		details["failed_step"] = stepErr.Step
+		details["rolled_back"] = stepErr.RolledBack
+	}
+	return details
+}
+
+// submitSheetData ставит запись в очередь фоновых задач и отвечает 202 Accepted
+func (sc *SheetsController) submitSheetData(w http.ResponseWriter, data models.SheetData) {
+	job, err := sc.sheetsControl.SubmitSheetData(data)
+	if err != nil {
+		sc.log.Errorw("Ошибка постановки задачи в очередь", "fiat", data.Fiat, "error", err)
+		if errors.Is(err, apperrors.ErrJobQueueFull) {
+			sc.sendErrorResponse(w, http.StatusServiceUnavailable, "Очередь задач переполнена")
+			return
+		}
+		sc.sendErrorResponse(w, http.StatusInternalServerError, "Ошибка постановки задачи в очередь")
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	sc.sendJSONResponse(w, http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "Задача поставлена в очередь",
+		Data:    job,
+	})
+}
+
+// GetJob возвращает состояние фоновой задачи записи
+// URL: GET /api/jobs/{id}
+func (sc *SheetsController) GetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	if id == "" || strings.Contains(id, "/") {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/jobs/{id}")
+		return
+	}
+
+	job, err := sc.sheetsControl.GetJob(id)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrJobNotFound) {
+			sc.sendErrorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		sc.log.Errorw("Ошибка получения задачи", "job_id", id, "error", err)
+		sc.sendErrorResponse(w, http.StatusInternalServerError, "Ошибка получения задачи")
+		return
+	}
+
+	sc.sendSuccessResponse(w, "Состояние задачи", job)
 }
 
 // DeleteSheet обрабатывает запрос на удаление листа из таблицы
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/models"
+	"GoogleSheetW/internal/services/sheetsControl"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UpsertSheet записывает один лист супа, не трогая RAW и другие листы. Тело запроса - models.Soup;
+// имя листа берётся из URL
+// URL: PUT /api/sheets/{fiat}/sheet/{sheetName}
+func (sc *SheetsController) UpsertSheet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sheets/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[1] != "sheet" || parts[2] == "" {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/sheets/{fiat}/sheet/{sheetName}")
+		return
+	}
+	fiat, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр fiat")
+		return
+	}
+	sheetName, err := url.QueryUnescape(parts[2])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр sheetName")
+		return
+	}
+
+	var soup models.Soup
+	if err := json.NewDecoder(r.Body).Decode(&soup); err != nil {
+		sc.log.Errorw("Ошибка декодирования JSON", "error", err)
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+	if soup.Name != "" && soup.Name != sheetName {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Имя листа в теле запроса не совпадает с именем в URL")
+		return
+	}
+	soup.Name = sheetName
+
+	outcome, err := sc.sheetsControl.UpsertSoup(fiat, soup)
+	var validationErr *apperrors.ValidationError
+	if errors.As(err, &validationErr) {
+		sc.sendErrorResponse(w, http.StatusBadRequest, validationErr.Msg)
+		return
+	}
+	sc.sendWriteResult(w, fiat, outcome, err)
+}
+
+// UpsertRaw записывает только данные RAW, не трогая листы супов. Тело запроса - models.RAWData;
+// ?mode=append дописывает строки под уже записанными вместо перезаписи листа
+// URL: PUT /api/sheets/{fiat}/raw
+func (sc *SheetsController) UpsertRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		sc.sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sheets/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "raw" {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат URL. Ожидается: /api/sheets/{fiat}/raw")
+		return
+	}
+	fiat, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр fiat")
+		return
+	}
+
+	var raw models.RAWData
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		sc.log.Errorw("Ошибка декодирования JSON", "error", err)
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный формат JSON")
+		return
+	}
+
+	var outcome sheetsControl.WriteOutcome
+	switch r.URL.Query().Get("mode") {
+	case "", "replace":
+		outcome, err = sc.sheetsControl.UpsertRaw(fiat, raw)
+	case "append":
+		outcome, err = sc.sheetsControl.AppendRaw(fiat, raw)
+	default:
+		sc.sendErrorResponse(w, http.StatusBadRequest, "Неверный параметр mode. Ожидается: replace или append")
+		return
+	}
+	var validationErr *apperrors.ValidationError
+	if errors.As(err, &validationErr) {
+		sc.sendErrorResponse(w, http.StatusBadRequest, validationErr.Msg)
+		return
+	}
+	sc.sendWriteResult(w, fiat, outcome, err)
+}
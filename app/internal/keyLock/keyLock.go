@@ -0,0 +1,40 @@
+package keyLock
+
+import "sync"
+
+// Locks - мьютексы по ключу (например, валюте): работа с разными ключами не ждёт друг друга.
+// Нулевое значение готово к использованию; мьютексы, которые никто не держит и не ждёт, удаляются
+type Locks struct {
+	locks map[string]*lock
+	mu    sync.Mutex
+}
+
+type lock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock захватывает мьютекс ключа и возвращает функцию его освобождения
+func (l *Locks) Lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*lock)
+	}
+	k, ok := l.locks[key]
+	if !ok {
+		k = &lock{}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.Lock()
+	return func() {
+		k.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if k.refs--; k.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
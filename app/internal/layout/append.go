@@ -0,0 +1,75 @@
+package layout
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"reflect"
+)
+
+// AppendValues делит запись листа для дозаписи таблицы: values - блоки без строк таблицы (перезаписываются
+// как обычно), table - область строк таблицы для values.append, rows - строки таблицы, которые дописываются
+// под уже записанными. Таблица должна быть в блоке без for_each, иначе её начало зависит от данных
+func (s *Sheet) AppendValues(sheetName string, data any) (values []*sheets.ValueRange, table string, rows [][]interface{}, err error) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	var tableBlock *Block
+	for i := range s.Blocks {
+		b := &s.Blocks[i]
+		if b.Table == nil || b.ForEach != "" {
+			if vr := b.values(sheetName, v); vr != nil {
+				values = append(values, vr)
+			}
+			continue
+		}
+		if tableBlock != nil {
+			return nil, "", nil, fmt.Errorf("в раскладке листа больше одной таблицы")
+		}
+		tableBlock = b
+	}
+	if tableBlock == nil {
+		return nil, "", nil, fmt.Errorf("в раскладке листа нет таблицы вне for_each")
+	}
+
+	// Строки блока и заголовок таблицы записываются на своё место, дописываются только строки данных
+	head := rowValues(tableBlock.Rows, v)
+	if len(tableBlock.Table.Columns) > 0 {
+		header := make([]interface{}, len(tableBlock.Table.Columns))
+		for i, column := range tableBlock.Table.Columns {
+			header[i] = column
+		}
+		head = append(head, header)
+	}
+	if len(head) > 0 {
+		width := 1
+		for _, row := range head {
+			width = max(width, len(row))
+		}
+		values = append(values, &sheets.ValueRange{
+			Range: a1Notation.Range{
+				Sheet:    sheetName,
+				StartRow: tableBlock.row,
+				StartCol: tableBlock.col,
+				EndRow:   tableBlock.row + len(head),
+				EndCol:   tableBlock.col + width,
+			}.String(),
+			Values: head,
+		})
+	}
+
+	lines := v.FieldByIndex(mustField(v.Type(), tableBlock.Table.Source).Index).Interface().([][]string)
+	for _, line := range lines {
+		row := make([]interface{}, len(line))
+		for i := range line {
+			row[i] = line[i]
+		}
+		rows = append(rows, row)
+	}
+	table = a1Notation.Range{
+		Sheet:    sheetName,
+		StartRow: tableBlock.row + len(head),
+		StartCol: tableBlock.col,
+		EndRow:   a1Notation.Unbounded,
+		EndCol:   tableBlock.col + tableBlock.width(),
+	}.String()
+	return values, table, rows, nil
+}
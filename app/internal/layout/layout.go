@@ -0,0 +1,404 @@
+package layout
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/models"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// defaultLayout - раскладка, повторяющая оформление листов до появления файла раскладки
+//
+//go:embed default.yaml
+var defaultLayout []byte
+
+// Cell - ячейка строки блока: постоянная подпись (в том числе формула) или поле данных
+type Cell struct {
+	Label string `yaml:"label"`
+	Field string `yaml:"field"` // имя поля в JSON запросе, например fixed_price
+}
+
+// Table - таблица после строк блока: строка заголовков и строки данных из поля Source
+type Table struct {
+	Source  string   `yaml:"source"`  // поле со строками данных, например data
+	Columns []string `yaml:"columns"` // заголовки; пусто - таблица без строки заголовков
+	Width   int      `yaml:"width"`   // число столбцов; по умолчанию - число заголовков
+}
+
+// Block - прямоугольная область листа, начинающаяся в ячейке Anchor
+type Block struct {
+	Anchor  string   `yaml:"anchor"`
+	Rows    [][]Cell `yaml:"rows"`
+	ForEach string   `yaml:"for_each"` // список, для каждого элемента которого повторяются Rows
+	Table   *Table   `yaml:"table"`
+	MaxRows int      `yaml:"max_rows"` // сколько строк очищать, если размер прошлой записи неизвестен; 0 - до конца листа
+
+	row, col int
+}
+
+// Sheet - раскладка одного вида листа
+type Sheet struct {
+	Init   []Block `yaml:"init"`   // записываются один раз при создании листа
+	Blocks []Block `yaml:"blocks"` // перезаписываются при каждом обновлении
+}
+
+// Layout описывает раскладку листов супов, листа RAW и листа RAW_filter
+type Layout struct {
+	Soup      Sheet `yaml:"soup"`
+	Raw       Sheet `yaml:"raw"`
+	RawFilter Sheet `yaml:"raw_filter"`
+}
+
+// Default возвращает встроенную раскладку
+func Default() *Layout {
+	l, err := Parse(defaultLayout)
+	if err != nil {
+		panic(fmt.Sprintf("встроенная раскладка листов некорректна: %v", err))
+	}
+	return l
+}
+
+// Load читает раскладку из файла YAML или JSON; пустой путь - встроенная раскладка
+func Load(path string) (*Layout, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл раскладки %s: %w", path, err)
+	}
+	l, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("файл раскладки %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Parse разбирает раскладку (JSON тоже является YAML) и проверяет, что все поля существуют в модели данных
+func Parse(data []byte) (*Layout, error) {
+	var l Layout
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&l); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("неверный формат раскладки: %v", err)
+	}
+	if err := l.Soup.validate("soup", reflect.TypeOf(models.Soup{})); err != nil {
+		return nil, err
+	}
+	if err := l.Raw.validate("raw", reflect.TypeOf(models.RAWData{})); err != nil {
+		return nil, err
+	}
+	if err := l.RawFilter.validate("raw_filter", nil); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// InitValues возвращает подписи и формулы, которые записываются в новый лист
+func (s *Sheet) InitValues(sheetName string) []*sheets.ValueRange {
+	var result []*sheets.ValueRange
+	for i := range s.Init {
+		if vr := s.Init[i].values(sheetName, reflect.Value{}); vr != nil {
+			result = append(result, vr)
+		}
+	}
+	return result
+}
+
+// rawPlaceholder - подстановка {raw!<диапазон>} в подписях листа RAW_filter
+var rawPlaceholder = regexp.MustCompile(`\{raw!([^}]+)\}`)
+
+// RawFilterValues возвращает содержимое листа RAW_filter для листов данных RAW, RAW_2, ...:
+// {raw!$A4:O} раскрывается в 'RAW'!$A4:O;'RAW_2'!$A4:O, чтобы формула охватывала все листы
+func (l *Layout) RawFilterValues(rawSheets []string) []*sheets.ValueRange {
+	values := l.RawFilter.InitValues("RAW_filter")
+	for _, vr := range values {
+		for _, row := range vr.Values {
+			for i, value := range row {
+				label, ok := value.(string)
+				if !ok {
+					continue
+				}
+				row[i] = rawPlaceholder.ReplaceAllStringFunc(label, func(match string) string {
+					rng := rawPlaceholder.FindStringSubmatch(match)[1]
+					ranges := make([]string, len(rawSheets))
+					for j, name := range rawSheets {
+						ranges[j] = a1Notation.QuoteSheet(name) + "!" + rng
+					}
+					return strings.Join(ranges, ";")
+				})
+			}
+		}
+	}
+	return values
+}
+
+// RawFilterArea возвращает ячейку формулы листа RAW_filter (строка и столбец с 0) и число столбцов,
+// которые она выбирает: ширину диапазона подстановки {raw!<диапазон>}, а без подстановки - ширину блока.
+// ok - false, если в раскладке RAW_filter нет подписей
+func (l *Layout) RawFilterArea() (row, col, width int, ok bool) {
+	for i := range l.RawFilter.Init {
+		b := &l.RawFilter.Init[i]
+		for r, cells := range b.Rows {
+			for c, cell := range cells {
+				if cell.Label == "" {
+					continue
+				}
+				width = b.width() - c
+				if m := rawPlaceholder.FindStringSubmatch(cell.Label); m != nil {
+					if rng, err := a1Notation.Parse("RAW!" + strings.ReplaceAll(m[1], "$", "")); err == nil && rng.EndCol != a1Notation.Unbounded {
+						width = rng.EndCol - rng.StartCol
+					}
+				}
+				return b.row + r, b.col + c, width, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Values возвращает записи блоков листа для данных data (models.Soup или models.RAWData)
+// и диапазоны, занятые блоками переменного размера: их нужно очистить перед следующей записью
+func (s *Sheet) Values(sheetName string, data any) (values []*sheets.ValueRange, extents []string) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	for i := range s.Blocks {
+		b := &s.Blocks[i]
+		vr := b.values(sheetName, v)
+		if vr == nil {
+			continue
+		}
+		values = append(values, vr)
+		if b.variable() {
+			extents = append(extents, vr.Range)
+		}
+	}
+	return values, extents
+}
+
+// ClearRanges возвращает области блоков переменного размера на случай, когда размер прошлой записи
+// неизвестен (новый лист или потерянный кэш): max_rows строк или, если он не задан, до конца листа
+func (s *Sheet) ClearRanges(sheetName string) []string {
+	var result []string
+	for i := range s.Blocks {
+		b := &s.Blocks[i]
+		if !b.variable() {
+			continue
+		}
+		r := a1Notation.Range{
+			Sheet:    sheetName,
+			StartRow: b.row,
+			StartCol: b.col,
+			EndRow:   a1Notation.Unbounded,
+			EndCol:   b.col + b.width(),
+		}
+		if b.MaxRows > 0 {
+			r.EndRow = b.row + b.MaxRows
+		}
+		result = append(result, r.String())
+	}
+	return result
+}
+
+func (s *Sheet) validate(name string, model reflect.Type) error {
+	for i := range s.Init {
+		if err := s.Init[i].validate(nil); err != nil {
+			return fmt.Errorf("%s.init[%d]: %w", name, i, err)
+		}
+	}
+	for i := range s.Blocks {
+		if err := s.Blocks[i].validate(model); err != nil {
+			return fmt.Errorf("%s.blocks[%d]: %w", name, i, err)
+		}
+	}
+	return nil
+}
+
+func (b *Block) validate(model reflect.Type) error {
+	row, col, err := a1Notation.ParseCell(b.Anchor)
+	if err != nil {
+		return fmt.Errorf("неверная ячейка anchor %q: %v", b.Anchor, err)
+	}
+	b.row, b.col = row, col
+
+	rowModel := model
+	if b.ForEach != "" {
+		field, ok := fieldByName(model, b.ForEach)
+		if !ok || field.Type.Kind() != reflect.Slice || field.Type.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("for_each: поле %q не является списком", b.ForEach)
+		}
+		rowModel = field.Type.Elem()
+	}
+	for _, row := range b.Rows {
+		for _, cell := range row {
+			if cell.Label != "" && cell.Field != "" {
+				return fmt.Errorf("в ячейке заданы и label %q, и field %q", cell.Label, cell.Field)
+			}
+			if cell.Field == "" {
+				continue
+			}
+			if _, ok := fieldByName(rowModel, cell.Field); !ok {
+				return fmt.Errorf("неизвестное поле %q", cell.Field)
+			}
+		}
+	}
+	if b.Table != nil {
+		field, ok := fieldByName(model, b.Table.Source)
+		if !ok || field.Type != reflect.TypeOf([][]string(nil)) {
+			return fmt.Errorf("table.source: поле %q не является таблицей", b.Table.Source)
+		}
+		if b.Table.Width < 0 {
+			return fmt.Errorf("table.width не может быть отрицательным")
+		}
+	}
+	if b.ForEach != "" && !b.hasLabels() && b.Table != nil && len(b.Table.Columns) == 0 {
+		// При чтении листа конец списка без подписей находится только по строке заголовков таблицы
+		return fmt.Errorf("for_each без подписей требует table.columns")
+	}
+	if b.MaxRows < 0 {
+		return fmt.Errorf("max_rows не может быть отрицательным")
+	}
+	return nil
+}
+
+// hasLabels сообщает, есть ли в строках блока подписи
+func (b *Block) hasLabels() bool {
+	for _, row := range b.Rows {
+		for _, cell := range row {
+			if cell.Label != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// variable сообщает, зависит ли размер блока от данных
+func (b *Block) variable() bool {
+	return b.ForEach != "" || b.Table != nil
+}
+
+// values собирает строки блока и возвращает запись ровно того диапазона, который они занимают;
+// nil, если записывать нечего
+func (b *Block) values(sheetName string, data reflect.Value) *sheets.ValueRange {
+	var rows [][]interface{}
+	if b.ForEach != "" {
+		items := data.FieldByIndex(mustField(data.Type(), b.ForEach).Index)
+		for i := 0; i < items.Len(); i++ {
+			rows = append(rows, rowValues(b.Rows, items.Index(i))...)
+		}
+	} else {
+		rows = rowValues(b.Rows, data)
+	}
+	if b.Table != nil {
+		if len(b.Table.Columns) > 0 {
+			header := make([]interface{}, len(b.Table.Columns))
+			for i, column := range b.Table.Columns {
+				header[i] = column
+			}
+			rows = append(rows, header)
+		}
+		table := data.FieldByIndex(mustField(data.Type(), b.Table.Source).Index).Interface().([][]string)
+		for _, line := range table {
+			row := make([]interface{}, len(line))
+			for i := range line {
+				row[i] = line[i]
+			}
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	width := 1
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if b.variable() {
+		// Строки дополняются пустыми ячейками до прямоугольника: запись затирает прежние значения во всей области
+		for i := range rows {
+			for len(rows[i]) < width {
+				rows[i] = append(rows[i], "")
+			}
+		}
+	}
+	return &sheets.ValueRange{
+		Range: a1Notation.Range{
+			Sheet:    sheetName,
+			StartRow: b.row,
+			StartCol: b.col,
+			EndRow:   b.row + len(rows),
+			EndCol:   b.col + width,
+		}.String(),
+		Values: rows,
+	}
+}
+
+// width - число столбцов, которое может занять блок
+func (b *Block) width() int {
+	width := 1
+	for _, row := range b.Rows {
+		width = max(width, len(row))
+	}
+	if b.Table != nil {
+		width = max(width, b.Table.Width, len(b.Table.Columns))
+	}
+	return width
+}
+
+func rowValues(rows [][]Cell, data reflect.Value) [][]interface{} {
+	result := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i, cell := range row {
+			if cell.Field == "" {
+				values[i] = cell.Label
+				continue
+			}
+			values[i] = cellValue(data.FieldByIndex(mustField(data.Type(), cell.Field).Index))
+		}
+		result = append(result, values)
+	}
+	return result
+}
+
+// cellValue возвращает значение поля для ячейки; список строк (например, банки) записывается через запятую
+func cellValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		return strings.Join(v.Interface().([]string), ", ")
+	}
+	return v.Interface()
+}
+
+// fieldByName ищет поле структуры по имени из JSON тега
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t == nil {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// mustField возвращает поле, проверенное при разборе раскладки
+func mustField(t reflect.Type, name string) reflect.StructField {
+	field, ok := fieldByName(t, name)
+	if !ok {
+		panic(fmt.Sprintf("поле %q отсутствует в %s", name, t))
+	}
+	return field
+}
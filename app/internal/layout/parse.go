@@ -0,0 +1,171 @@
+package layout
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Parse разбирает значения листа, прочитанные из таблицы, обратно в target (*models.Soup или *models.RAWData)
+// по тем же блокам, по которым данные записываются. Блоки init (подписи) не разбираются
+func (s *Sheet) Parse(rows [][]string, target any) error {
+	v := reflect.ValueOf(target).Elem()
+	for i := range s.Blocks {
+		if err := s.Blocks[i].parse(rows, v); err != nil {
+			return fmt.Errorf("блок %s: %w", s.Blocks[i].Anchor, err)
+		}
+	}
+	return nil
+}
+
+func (b *Block) parse(rows [][]string, data reflect.Value) error {
+	row := b.row
+	if b.ForEach != "" {
+		// Элементы списка идут подряд, пока подписи строк блока совпадают с прочитанными
+		items := data.FieldByIndex(mustField(data.Type(), b.ForEach).Index)
+		items.Set(reflect.MakeSlice(items.Type(), 0, 0))
+		for len(b.Rows) > 0 && b.matches(rows, row) {
+			item := reflect.New(items.Type().Elem()).Elem()
+			if err := parseRows(b.Rows, rows, row, b.col, item); err != nil {
+				return err
+			}
+			items.Set(reflect.Append(items, item))
+			row += len(b.Rows)
+		}
+	} else {
+		if err := parseRows(b.Rows, rows, row, b.col, data); err != nil {
+			return err
+		}
+		row += len(b.Rows)
+	}
+
+	if b.Table != nil {
+		if len(b.Table.Columns) > 0 {
+			row++
+		}
+		// Таблица занимает все строки до конца листа
+		table := [][]string{}
+		for ; row < len(rows); row++ {
+			line := make([]string, 0, b.width())
+			for col := b.col; col < b.col+b.width(); col++ {
+				line = append(line, cellAt(rows, row, col))
+			}
+			for len(line) > 0 && line[len(line)-1] == "" {
+				line = line[:len(line)-1]
+			}
+			table = append(table, line)
+		}
+		data.FieldByIndex(mustField(data.Type(), b.Table.Source).Index).Set(reflect.ValueOf(table))
+	}
+	return nil
+}
+
+// matches сообщает, начинается ли со строки row очередной элемент блока for_each:
+// подписи совпадают, а если подписей нет - в строках элемента есть значения и строка row
+// не является строкой заголовков таблицы блока
+func (b *Block) matches(rows [][]string, row int) bool {
+	if row >= len(rows) {
+		return false
+	}
+	if !b.hasLabels() && b.isTableHeader(rows, row) {
+		return false
+	}
+	labels, filled := 0, false
+	for i, cells := range b.Rows {
+		for j, cell := range cells {
+			value := cellAt(rows, row+i, b.col+j)
+			filled = filled || value != ""
+			if cell.Label == "" {
+				continue
+			}
+			if value != cell.Label {
+				return false
+			}
+			labels++
+		}
+	}
+	return labels > 0 || filled
+}
+
+// isTableHeader сообщает, записана ли в строке row строка заголовков таблицы блока
+func (b *Block) isTableHeader(rows [][]string, row int) bool {
+	if b.Table == nil || len(b.Table.Columns) == 0 {
+		return false
+	}
+	for i, column := range b.Table.Columns {
+		if cellAt(rows, row, b.col+i) != column {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRows(cells [][]Cell, rows [][]string, startRow, startCol int, data reflect.Value) error {
+	for i, row := range cells {
+		for j, cell := range row {
+			if cell.Field == "" {
+				continue
+			}
+			field := data.FieldByIndex(mustField(data.Type(), cell.Field).Index)
+			if err := setField(field, cellAt(rows, startRow+i, startCol+j)); err != nil {
+				return fmt.Errorf("поле %s: %v", cell.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setField записывает значение ячейки в поле; обратная операция к cellValue
+func setField(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("тип %s не поддерживается", v.Type())
+		}
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ", ")
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q не является числом", value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			v.SetFloat(0)
+			return nil
+		}
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q не является числом", value)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil && value != "" {
+			return fmt.Errorf("%q не является логическим значением", value)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("тип %s не поддерживается", v.Type())
+	}
+	return nil
+}
+
+// cellAt возвращает значение ячейки; ячейки за пределами прочитанных значений пусты
+func cellAt(rows [][]string, row, col int) string {
+	if row < 0 || row >= len(rows) || col < 0 || col >= len(rows[row]) {
+		return ""
+	}
+	return rows[row][col]
+}
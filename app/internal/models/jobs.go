@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// JobStatus состояние фоновой задачи записи данных
+type JobStatus string
+
+const (
+	JobQueued     JobStatus = "queued"
+	JobRunning    JobStatus = "running"
+	JobSucceeded  JobStatus = "succeeded"
+	JobSuperseded JobStatus = "superseded"
+	JobDeferred   JobStatus = "deferred" // запись не удалась, данные будут отправлены повторно из журнала
+	JobFailed     JobStatus = "failed"
+)
+
+// BackendCall описывает один запрос к хранилищу таблиц
+type BackendCall struct {
+	Method        string    `json:"method"`
+	SpreadsheetID string    `json:"spreadsheet_id,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	Duration      float64   `json:"duration_seconds"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Job фоновая задача записи данных валюты
+type Job struct {
+	ID         string        `json:"id"`
+	Fiat       string        `json:"fiat"`
+	Status     JobStatus     `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Calls      []BackendCall `json:"calls"`
+	Error      string        `json:"error,omitempty"`
+
+	// Шаг, на котором запись прервалась, и отменены ли изменения предыдущих шагов
+	FailedStep string `json:"failed_step,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+}
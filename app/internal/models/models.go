@@ -1,5 +1,7 @@
 package models
 
+import "strings"
+
 type InfoFilterSheet struct {
 	Exchange                 string   `json:"exchange"`
 	BanksName                []string `json:"banks_name"`
@@ -32,3 +34,37 @@ type SheetData struct {
 	SoupList []Soup  `json:"soup_list"`
 	RAWData  RAWData `json:"raw_data"`
 }
+
+// WriteScope - какие листы валюты обновляет запись
+type WriteScope string
+
+const (
+	ScopeAll  WriteScope = ""      // все листы: супы из SoupList и RAW
+	ScopeSoup WriteScope = "sheet" // только листы супов из SoupList
+	ScopeRaw  WriteScope = "raw"   // только листы RAW и RAW_filter
+
+	// ScopeRawAppend дописывает строки RAW под уже записанными вместо перезаписи листа
+	ScopeRawAppend WriteScope = "raw_append"
+)
+
+// Coalesces сообщает, могут ли более новые данные заменить ожидающие: дописываемые строки не заменяют друг друга
+func (s WriteScope) Coalesces() bool {
+	return s != ScopeRawAppend
+}
+
+// Key возвращает ключ очереди записи: более новые данные с тем же ключом заменяют ожидающие.
+// Частичные записи разных листов одной валюты не заменяют друг друга
+func (s WriteScope) Key(data SheetData) string {
+	switch s {
+	case ScopeSoup:
+		names := make([]string, 0, len(data.SoupList))
+		for _, soup := range data.SoupList {
+			names = append(names, soup.Name)
+		}
+		return data.Fiat + "/sheet/" + strings.Join(names, ",")
+	case ScopeRaw, ScopeRawAppend:
+		return data.Fiat + "/raw"
+	default:
+		return data.Fiat
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OutboxEntry сведения о неотправленных данных валюты в журнале исходящих данных
+type OutboxEntry struct {
+	Seq        uint64     `json:"seq"`
+	Fiat       string     `json:"fiat"`
+	Scope      WriteScope `json:"scope,omitempty"`
+	EnqueuedAt time.Time  `json:"enqueued_at"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error,omitempty"`
+	InFlight   bool       `json:"in_flight"`
+	Sheets     []string   `json:"sheets"`
+	RawRows    int        `json:"raw_rows"`
+}
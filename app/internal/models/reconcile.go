@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ReconcileReport - изменения кэша после сверки с хранилищем таблиц
+type ReconcileReport struct {
+	StartedAt           time.Time           `json:"started_at"`
+	FinishedAt          time.Time           `json:"finished_at"`
+	Spreadsheets        int                 `json:"spreadsheets"`         // таблиц в хранилище
+	AddedSpreadsheets   []string            `json:"added_spreadsheets"`   // валюты
+	RemovedSpreadsheets []string            `json:"removed_spreadsheets"` // валюты
+	ChangedSpreadsheets []string            `json:"changed_spreadsheets"` // валюты, у которых сменился ID таблицы
+	AddedSheets         map[string][]string `json:"added_sheets"`         // fiat -> листы
+	RemovedSheets       map[string][]string `json:"removed_sheets"`       // fiat -> листы
+	Errors              []string            `json:"errors,omitempty"`     // таблицы, которые не удалось сверить
+
+	// Таблицы без метки сервиса не попадают в кэш, если не включено их присвоение
+	UntaggedSpreadsheets  []string            `json:"untagged_spreadsheets"`  // названия
+	AdoptedSpreadsheets   []string            `json:"adopted_spreadsheets"`   // валюты, таблицам которых добавлена метка
+	DuplicateSpreadsheets map[string][]string `json:"duplicate_spreadsheets"` // fiat -> ID всех таблиц валюты
+}
+
+// Changed сообщает, изменился ли кэш
+func (r ReconcileReport) Changed() bool {
+	return len(r.AddedSpreadsheets)+len(r.RemovedSpreadsheets)+len(r.ChangedSpreadsheets)+
+		len(r.AddedSheets)+len(r.RemovedSheets) > 0
+}
@@ -5,6 +5,26 @@ type SetSheetDataRequest struct {
 	SheetData SheetData `json:"sheet_data"`
 }
 
+// BulkSetSheetDataRequest структура запроса пакетной записи данных нескольких валют
+type BulkSetSheetDataRequest struct {
+	SheetData []SheetData `json:"sheet_data"`
+}
+
+// BulkWriteResult результат записи данных одной валюты из пакетного запроса
+type BulkWriteResult struct {
+	Fiat       string `json:"fiat"`
+	Status     string `json:"status"` // written, superseded, deferred или failed
+	Error      string `json:"error,omitempty"`
+	FailedStep string `json:"failed_step,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+}
+
+// BulkSetSheetDataResponse результаты пакетной записи в порядке данных запроса
+type BulkSetSheetDataResponse struct {
+	Results []BulkWriteResult `json:"results"`
+	Failed  int               `json:"failed"`
+}
+
 // APIResponse общая структура ответа API
 type APIResponse struct {
 	Success bool        `json:"success"`
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SheetState - размер последней записи в лист: области блоков переменного размера,
+// которые очищаются перед следующей записью, и известные ID и размер сетки листа
+type SheetState struct {
+	Extents   []string  `json:"extents"`             // диапазоны в A1-нотации
+	SheetID   *int64    `json:"sheet_id,omitempty"`  // nil - ID листа неизвестен
+	GridRows  int64     `json:"grid_rows,omitempty"` // 0 - размер сетки неизвестен
+	GridCols  int64     `json:"grid_cols,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"` // время последней записи; нулевое, если сервис в лист не писал
+}
+
+// Written сообщает, записывал ли сервис данные в лист, то есть известны ли Extents
+func (s SheetState) Written() bool {
+	return !s.UpdatedAt.IsZero()
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// FiatSpreadsheet - таблица валюты и её листы
+type FiatSpreadsheet struct {
+	Fiat          string     `json:"fiat"`
+	SpreadsheetID string     `json:"spreadsheet_id"`
+	URL           string     `json:"url"`
+	Sheets        []SheetTab `json:"sheets"`
+}
+
+// SheetTab - лист таблицы; ID, размер сетки и время записи известны, если лист записывался
+// сервисом или был прочитан из хранилища при сверке
+type SheetTab struct {
+	Title       string     `json:"title"`
+	SheetID     *int64     `json:"sheet_id,omitempty"`
+	RowCount    int64      `json:"row_count,omitempty"`
+	ColumnCount int64      `json:"column_count,omitempty"`
+	LastWriteAt *time.Time `json:"last_write_at,omitempty"`
+}
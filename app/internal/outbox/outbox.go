@@ -0,0 +1,381 @@
+package outbox
+
+import (
+	"GoogleSheetW/internal/models"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// compactThreshold - сколько лишних записей журнала допускается до его перезаписи
+const compactThreshold = 100
+
+// Entry - данные валюты, ещё не отправленные в Google
+type Entry struct {
+	Seq        uint64            `json:"seq"`
+	Data       models.SheetData  `json:"data"`
+	Scope      models.WriteScope `json:"scope,omitempty"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+	Attempts   int               `json:"attempts"`
+	LastError  string            `json:"last_error,omitempty"`
+	InFlight   bool              `json:"in_flight"`
+	Append     *AppendMark       `json:"append,omitempty"`
+}
+
+// AppendMark - состояние листа RAW перед дозаписью строк: сколько строк в нём было и сколько дописывается.
+// По нему повтор дозаписи с неизвестным результатом узнаёт, дописаны ли строки
+type AppendMark struct {
+	Base int `json:"base"`
+	Rows int `json:"rows"`
+}
+
+// record - строка журнала: put добавляет данные, ack подтверждает их отправку,
+// append отмечает начало дозаписи строк RAW, seq хранит последний выданный номер
+type record struct {
+	Op     string      `json:"op"`
+	Seq    uint64      `json:"seq"`
+	Entry  *Entry      `json:"entry,omitempty"`
+	Append *AppendMark `json:"append,omitempty"`
+}
+
+// Outbox - журнал исходящих данных в файле (append-only, одна JSON-запись на строку).
+// Данные записываются на диск до отправки в Google и удаляются после подтверждения.
+// Для каждой валюты хранится только последняя версия данных (для частичных записей - каждого листа)
+type Outbox struct {
+	path    string
+	file    *os.File
+	pending map[string]*Entry // ключ: models.WriteScope.Key
+	seq     uint64
+	records int // число записей в файле журнала
+	mu      sync.Mutex
+}
+
+// Open открывает журнал, восстанавливая неотправленные данные из файла
+func Open(path string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог журнала: %v", err)
+	}
+
+	o := &Outbox{
+		path:    path,
+		pending: make(map[string]*Entry),
+	}
+	if err := o.replay(); err != nil {
+		return nil, err
+	}
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// replay читает журнал и восстанавливает последние неподтверждённые данные каждой валюты
+func (o *Outbox) replay() error {
+	file, err := os.Open(o.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал %s: %v", o.path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Недописанная последняя строка после аварийного завершения
+			break
+		}
+		// Номер берётся из записи любого вида, включая seq, которой compact сохраняет последний номер
+		o.seq = max(o.seq, rec.Seq)
+		switch rec.Op {
+		case "put":
+			if rec.Entry != nil {
+				o.putLocked(rec.Entry)
+			}
+		case "ack":
+			o.ackLocked(rec.Seq)
+		case "append":
+			if entry := o.find(rec.Seq); entry != nil {
+				entry.Append = rec.Append
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать журнал %s: %v", o.path, err)
+	}
+	return nil
+}
+
+// Put сохраняет данные на диск и возвращает их номер. Запись сразу помечается как отправляемая
+func (o *Outbox) Put(data models.SheetData, scope models.WriteScope) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	entry := &Entry{
+		Seq:        o.seq,
+		Data:       data,
+		Scope:      scope,
+		EnqueuedAt: time.Now(),
+	}
+	if err := o.append(record{Op: "put", Seq: entry.Seq, Entry: entry}); err != nil {
+		return 0, err
+	}
+	entry.InFlight = true
+	o.putLocked(entry)
+	return entry.Seq, nil
+}
+
+// Ack подтверждает отправку данных. Если для валюты уже есть более новые данные, они остаются в журнале
+func (o *Outbox) Ack(seq uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.append(record{Op: "ack", Seq: seq}); err != nil {
+		return err
+	}
+	o.ackLocked(seq)
+	return o.maybeCompact()
+}
+
+// Fail отмечает неудачную попытку отправки; данные остаются в журнале для повтора
+func (o *Outbox) Fail(seq uint64, cause error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if entry := o.find(seq); entry != nil {
+		entry.Attempts++
+		entry.LastError = cause.Error()
+		entry.InFlight = false
+	}
+}
+
+// MarkAppend сохраняет на диск состояние листа RAW перед дозаписью строк данных seq.
+// Вызывается до отправки строк, чтобы повтор после сбоя не дописал их второй раз
+func (o *Outbox) MarkAppend(seq uint64, mark AppendMark) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := o.find(seq)
+	if entry == nil {
+		return fmt.Errorf("данные %d отсутствуют в журнале", seq)
+	}
+	if err := o.append(record{Op: "append", Seq: seq, Append: &mark}); err != nil {
+		return err
+	}
+	entry.Append = &mark
+	return nil
+}
+
+// AppendMarkOf возвращает состояние листа RAW, сохранённое перед дозаписью данных seq.
+// false - дозапись этих данных ещё не начиналась
+func (o *Outbox) AppendMarkOf(seq uint64) (AppendMark, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := o.find(seq)
+	if entry == nil || entry.Append == nil {
+		return AppendMark{}, false
+	}
+	return *entry.Append, true
+}
+
+// HasEarlier сообщает, есть ли в журнале неотправленные данные с тем же ключом, что у seq,
+// но с меньшим номером. Так дописываемые строки RAW одной валюты отправляются в порядке поступления
+func (o *Outbox) HasEarlier(seq uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := o.find(seq)
+	if entry == nil {
+		return false
+	}
+	key := entry.Scope.Key(entry.Data)
+	for _, other := range o.pending {
+		if other.Seq < seq && other.Scope.Key(other.Data) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim помечает данные как отправляемые. Возвращает false, если данных уже нет или их отправляют
+func (o *Outbox) Claim(seq uint64) (Entry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := o.find(seq)
+	if entry == nil || entry.InFlight {
+		return Entry{}, false
+	}
+	entry.InFlight = true
+	return *entry, true
+}
+
+// IsLatest сообщает, остаются ли данные с номером seq последними для своего ключа. false - данные
+// уже подтверждены или заменены более новыми, и отправлять их не нужно
+func (o *Outbox) IsLatest(seq uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.find(seq) != nil
+}
+
+// Pending возвращает неотправленные данные в порядке поступления
+func (o *Outbox) Pending() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]Entry, 0, len(o.pending))
+	for _, entry := range o.pending {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// Len возвращает число неотправленных записей
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.pending)
+}
+
+// Close закрывает файл журнала
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+func (o *Outbox) putLocked(entry *Entry) {
+	key := entry.Scope.Key(entry.Data)
+	if !entry.Scope.Coalesces() {
+		key += "/" + strconv.FormatUint(entry.Seq, 10)
+	}
+	if current, ok := o.pending[key]; ok && current.Seq > entry.Seq {
+		return
+	}
+	o.pending[key] = entry
+}
+
+func (o *Outbox) ackLocked(seq uint64) {
+	for key, entry := range o.pending {
+		if entry.Seq == seq {
+			delete(o.pending, key)
+			return
+		}
+	}
+}
+
+func (o *Outbox) find(seq uint64) *Entry {
+	for _, entry := range o.pending {
+		if entry.Seq == seq {
+			return entry
+		}
+	}
+	return nil
+}
+
+// append дописывает запись в журнал и сбрасывает её на диск
+func (o *Outbox) append(rec record) error {
+	if o.file == nil {
+		return fmt.Errorf("журнал %s закрыт", o.path)
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запись журнала: %v", err)
+	}
+	line = append(line, '\n')
+	if _, err := o.file.Write(line); err != nil {
+		return fmt.Errorf("не удалось записать в журнал %s: %v", o.path, err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("не удалось сохранить журнал %s: %v", o.path, err)
+	}
+	o.records++
+	return nil
+}
+
+// maybeCompact перезаписывает журнал, когда в нём накопилось много отработанных записей
+func (o *Outbox) maybeCompact() error {
+	if o.records < len(o.pending)+compactThreshold {
+		return nil
+	}
+	return o.compact()
+}
+
+// compact записывает в новый файл только актуальные данные и атомарно заменяет им журнал
+func (o *Outbox) compact() error {
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл журнала: %v", err)
+	}
+
+	entries := make([]*Entry, 0, len(o.pending))
+	for _, entry := range o.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	// Номер сохраняется и без неотправленных данных: иначе после перезапуска номера начнутся заново,
+	// и новые данные окажутся «раньше» уже отправленных
+	if err := encoder.Encode(record{Op: "seq", Seq: o.seq}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать журнал: %v", err)
+	}
+	for _, entry := range entries {
+		stored := *entry
+		stored.InFlight = false
+		if err := encoder.Encode(record{Op: "put", Seq: entry.Seq, Entry: &stored}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("не удалось записать журнал: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать журнал: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось сохранить журнал: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть журнал: %v", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+	if err := os.Rename(tmpPath, o.path); err != nil {
+		return fmt.Errorf("не удалось заменить журнал: %v", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал %s: %v", o.path, err)
+	}
+	o.file = file
+	o.records = len(entries) + 1
+	return nil
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"GoogleSheetW/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+// Номера данных не начинаются заново после перезапуска, даже если все данные уже подтверждены
+// и журнал при открытии перезаписан без них
+func TestSeqSurvivesCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	journal, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	seq, err := journal.Put(models.SheetData{Fiat: "USD"}, models.ScopeAll)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := journal.Ack(seq); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	journal.Close()
+
+	// Каждое открытие перезаписывает журнал только актуальными данными
+	for range 2 {
+		if journal, err = Open(path); err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		journal.Close()
+	}
+
+	journal, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer journal.Close()
+	next, err := journal.Put(models.SheetData{Fiat: "USD"}, models.ScopeAll)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if next <= seq {
+		t.Fatalf("номер после перезапуска %d, want больше %d", next, seq)
+	}
+}
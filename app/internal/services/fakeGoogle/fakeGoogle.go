@@ -0,0 +1,578 @@
+package fakeGoogle
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/sheets/v4"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault описывает ошибку, которую сервер вернёт вместо обработки запроса
+type Fault struct {
+	Method     string        // HTTP метод; пустая строка - любой
+	Path       string        // подстрока пути запроса; пустая строка - любой путь
+	Status     int           // HTTP статус ответа, например 429 или 503
+	RetryAfter time.Duration // значение заголовка Retry-After; 0 - заголовок не передаётся
+	Times      int           // сколько раз вернуть ошибку
+}
+
+// Server - локальная замена подмножества REST API Sheets v4 и Drive v3, которое использует googleAPI.
+// Состояние таблиц хранится в memoryBackend.MemoryBackend
+type Server struct {
+	*httptest.Server
+	Store *memoryBackend.MemoryBackend
+
+	faults   []*Fault
+	requests []string
+	parents  map[string][]string    // ID таблицы -> папки; таблицы без записи лежат в "root"
+	folders  map[string]*drive.File // ID -> папка
+	mu       sync.Mutex
+}
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// Условия запроса Files.List, которые понимает замена: "'<id>' in parents" и "name='<имя>'"
+var (
+	parentsQuery = regexp.MustCompile(`'((?:[^'\\]|\\.)*)' in parents`)
+	nameQuery    = regexp.MustCompile(`name='((?:[^'\\]|\\.)*)'`)
+)
+
+// New запускает сервер; адрес для googleAPI.GetSheetsService и GetDriveService - поле URL
+func New() *Server {
+	s := &Server{Store: memoryBackend.New(), parents: make(map[string][]string), folders: make(map[string]*drive.File)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// InjectFault добавляет ошибку, срабатывающую на подходящих запросах
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fault := f
+	s.faults = append(s.faults, &fault)
+}
+
+// Requests возвращает список обработанных запросов в виде "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// ResetRequests очищает журнал запросов
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.applyFault(w, r) {
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/v4/spreadsheets"):
+		s.handleSheets(w, r, strings.TrimPrefix(path, "/v4/spreadsheets"))
+	case strings.HasPrefix(path, "/drive/v3/files"):
+		s.handleDrive(w, r, strings.TrimPrefix(path, "/drive/v3/files"))
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("неизвестный путь %s", path))
+	}
+}
+
+// applyFault записывает запрос в журнал и, если для него задана ошибка, отвечает ею
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method || !strings.Contains(r.URL.Path, f.Path) {
+			continue
+		}
+		f.Times--
+		if f.Times <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+		}
+		writeError(w, f.Status, statusName(f.Status), "injected fault")
+		return true
+	}
+	return false
+}
+
+func (s *Server) handleSheets(w http.ResponseWriter, r *http.Request, rest string) {
+	rest = strings.TrimPrefix(rest, "/")
+	if rest == "" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "INVALID_ARGUMENT", "метод не поддерживается")
+			return
+		}
+		s.createSpreadsheet(w, r)
+		return
+	}
+
+	id, action, _ := strings.Cut(rest, "/")
+	switch {
+	case action == "" && strings.HasSuffix(id, ":batchUpdate"):
+		s.batchUpdate(w, r, strings.TrimSuffix(id, ":batchUpdate"))
+	case action == "" && r.Method == http.MethodGet:
+		s.getSpreadsheet(w, id)
+	case action == "values:batchUpdate":
+		s.valuesBatchUpdate(w, r, id)
+	case strings.HasPrefix(action, "values/") && strings.HasSuffix(action, ":append") && r.Method == http.MethodPost:
+		s.valuesAppend(w, r, id, strings.TrimSuffix(strings.TrimPrefix(action, "values/"), ":append"))
+	case strings.HasPrefix(action, "values/") && r.Method == http.MethodGet:
+		s.valuesGet(w, id, strings.TrimPrefix(action, "values/"))
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("неизвестный метод %s", rest))
+	}
+}
+
+func (s *Server) handleDrive(w http.ResponseWriter, r *http.Request, rest string) {
+	rest = strings.TrimPrefix(rest, "/")
+	id, action, _ := strings.Cut(rest, "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		s.listFiles(w, r)
+	case id == "" && r.Method == http.MethodPost:
+		s.createFile(w, r)
+	case id != "" && action == "" && r.Method == http.MethodGet:
+		s.getFile(w, id)
+	case id != "" && action == "" && r.Method == http.MethodDelete:
+		s.deleteFile(w, id)
+	case id != "" && action == "" && r.Method == http.MethodPatch:
+		s.updateFile(w, r, id)
+	case id != "" && action == "permissions" && r.Method == http.MethodPost:
+		s.createPermission(w, r, id)
+	case id != "" && action == "copy" && r.Method == http.MethodPost:
+		s.copyFile(w, r, id)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("неизвестный метод %s %s", r.Method, rest))
+	}
+}
+
+func (s *Server) createSpreadsheet(w http.ResponseWriter, r *http.Request) {
+	var req sheets.Spreadsheet
+	if !decode(w, r, &req) {
+		return
+	}
+	title := ""
+	if req.Properties != nil {
+		title = req.Properties.Title
+	}
+	id, err := s.Store.CreateSpreadsheet(title)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	s.getSpreadsheet(w, id)
+}
+
+func (s *Server) getSpreadsheet(w http.ResponseWriter, id string) {
+	list, err := s.Store.ListSheets(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Requested entity was not found: %v", err))
+		return
+	}
+	title := ""
+	files, _ := s.Store.ListSpreadsheets()
+	for _, f := range files {
+		if f.ID == id {
+			title = f.Title
+		}
+	}
+
+	resp := sheets.Spreadsheet{
+		SpreadsheetId: id,
+		Properties:    &sheets.SpreadsheetProperties{Title: title},
+	}
+	for i, sh := range list {
+		resp.Sheets = append(resp.Sheets, &sheets.Sheet{Properties: &sheets.SheetProperties{
+			SheetId: sh.SheetID,
+			Title:   sh.Title,
+			Index:   int64(i),
+			GridProperties: &sheets.GridProperties{
+				RowCount:    sh.RowCount,
+				ColumnCount: sh.ColumnCount,
+			},
+		}})
+	}
+	writeJSON(w, resp)
+}
+
+// batchUpdate применяет запросы атомарно через MemoryBackend.BatchUpdate
+func (s *Server) batchUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var req sheets.BatchUpdateSpreadsheetRequest
+	if !decode(w, r, &req) || !s.exists(w, id) {
+		return
+	}
+	replies, err := s.Store.BatchUpdate(id, req.Requests)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	writeJSON(w, sheets.BatchUpdateSpreadsheetResponse{SpreadsheetId: id, Replies: replies})
+}
+
+func (s *Server) valuesBatchUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var req sheets.BatchUpdateValuesRequest
+	if !decode(w, r, &req) || !s.exists(w, id) {
+		return
+	}
+	if err := s.Store.WriteValues(id, req.Data); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	writeJSON(w, sheets.BatchUpdateValuesResponse{SpreadsheetId: id, TotalUpdatedSheets: int64(len(req.Data))})
+}
+
+// valuesAppend дописывает строки под таблицей; поддерживается только INSERT_ROWS
+func (s *Server) valuesAppend(w http.ResponseWriter, r *http.Request, id, rng string) {
+	var req sheets.ValueRange
+	if !decode(w, r, &req) || !s.exists(w, id) {
+		return
+	}
+	updated, err := s.Store.AppendValues(id, rng, req.Values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	writeJSON(w, sheets.AppendValuesResponse{
+		SpreadsheetId: id,
+		TableRange:    rng,
+		Updates:       &sheets.UpdateValuesResponse{SpreadsheetId: id, UpdatedRange: updated, UpdatedRows: int64(len(req.Values))},
+	})
+}
+
+// valuesGet отдаёт значения всего листа; формулы возвращаются без вычисления
+func (s *Server) valuesGet(w http.ResponseWriter, id, rng string) {
+	if !s.exists(w, id) {
+		return
+	}
+	r, err := a1Notation.Parse(rng)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Unable to parse range: "+rng)
+		return
+	}
+	values, err := s.Store.ReadValues(id, r.Sheet)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Unable to parse range: "+rng)
+		return
+	}
+	rows := make([][]interface{}, len(values))
+	for i, row := range values {
+		rows[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			rows[i][j] = value
+		}
+	}
+	writeJSON(w, sheets.ValueRange{Range: rng, MajorDimension: "ROWS", Values: rows})
+}
+
+// listFiles отдаёт таблицы или папки постранично (pageSize, pageToken) с учётом условий
+// "'<id>' in parents" (любое из перечисленных) и "name='<имя>'"
+func (s *Server) listFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	var files []*drive.File
+	if strings.Contains(query, folderMimeType) {
+		files = s.folderList()
+	} else {
+		list, err := s.Store.ListSpreadsheets()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+			return
+		}
+		for _, f := range list {
+			file := &drive.File{
+				Id:       f.ID,
+				Name:     f.Title,
+				MimeType: "application/vnd.google-apps.spreadsheet",
+				Parents:  s.fileParents(f.ID),
+			}
+			if f.Managed {
+				file.AppProperties = map[string]string{
+					backend.AppPropertyManagedBy: backend.ManagedByValue,
+					backend.AppPropertyFiat:      f.Fiat,
+				}
+			}
+			files = append(files, file)
+		}
+	}
+
+	var parents []string
+	for _, match := range parentsQuery.FindAllStringSubmatch(query, -1) {
+		parents = append(parents, unescapeQuery(match[1]))
+	}
+	name, byName := "", false
+	if match := nameQuery.FindStringSubmatch(query); match != nil {
+		name, byName = unescapeQuery(match[1]), true
+	}
+	files = slices.DeleteFunc(files, func(f *drive.File) bool {
+		inParents := len(parents) == 0 || slices.ContainsFunc(f.Parents, func(p string) bool { return slices.Contains(parents, p) })
+		return !inParents || byName && f.Name != name
+	})
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	resp := drive.FileList{Files: files[min(offset, len(files)):min(offset+pageSize, len(files))]}
+	if offset+pageSize < len(files) {
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+	writeJSON(w, resp)
+}
+
+func unescapeQuery(value string) string {
+	return strings.NewReplacer("\\\\", "\\", "\\'", "'").Replace(value)
+}
+
+// folderList возвращает папки в порядке создания
+func (s *Server) folderList() []*drive.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folders := make([]*drive.File, 0, len(s.folders))
+	for _, folder := range s.folders {
+		copied := *folder
+		copied.Parents = slices.Clone(folder.Parents)
+		folders = append(folders, &copied)
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].CreatedTime < folders[j].CreatedTime })
+	return folders
+}
+
+// createFile поддерживает только создание папок; таблицы создаются через Sheets API
+func (s *Server) createFile(w http.ResponseWriter, r *http.Request) {
+	var req drive.File
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.MimeType != folderMimeType {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "заменой Google API поддерживается только создание папок")
+		return
+	}
+	if len(req.Parents) == 0 {
+		req.Parents = []string{"root"}
+	}
+
+	s.mu.Lock()
+	folder := &drive.File{
+		Id:          fmt.Sprintf("folder-%d", len(s.folders)+1),
+		Name:        req.Name,
+		MimeType:    folderMimeType,
+		Parents:     req.Parents,
+		CreatedTime: fmt.Sprintf("%020d", len(s.folders)+1),
+	}
+	s.folders[folder.Id] = folder
+	s.mu.Unlock()
+
+	writeJSON(w, drive.File{Id: folder.Id, Name: folder.Name, MimeType: folder.MimeType, Parents: folder.Parents})
+}
+
+// copyFile копирует таблицу-шаблон в указанные папки (по умолчанию в "root")
+func (s *Server) copyFile(w http.ResponseWriter, r *http.Request, id string) {
+	var req drive.File
+	if !decode(w, r, &req) {
+		return
+	}
+	copyID, err := s.Store.CopySpreadsheet(id, req.Name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s", id))
+		return
+	}
+	if len(req.Parents) > 0 {
+		s.mu.Lock()
+		s.parents[copyID] = req.Parents
+		s.mu.Unlock()
+	}
+	writeJSON(w, drive.File{Id: copyID, Name: req.Name, Parents: s.fileParents(copyID)})
+}
+
+// FolderPath возвращает имена папок от корня до папки, в которой лежит таблица (по первому из родителей)
+func (s *Server) FolderPath(fileID string) []string {
+	parents := s.fileParents(fileID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var path []string
+	for len(parents) > 0 {
+		folder, ok := s.folders[parents[0]]
+		if !ok {
+			path = append([]string{parents[0]}, path...)
+			break
+		}
+		path = append([]string{folder.Name}, path...)
+		parents = folder.Parents
+	}
+	return path
+}
+
+func (s *Server) deleteFile(w http.ResponseWriter, id string) {
+	if err := s.Store.DeleteSpreadsheet(id); err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getFile отдаёт ID, название и папки таблицы
+func (s *Server) getFile(w http.ResponseWriter, id string) {
+	for _, folder := range s.folderList() {
+		if folder.Id == id {
+			writeJSON(w, folder)
+			return
+		}
+	}
+	list, _ := s.Store.ListSpreadsheets()
+	for _, f := range list {
+		if f.ID == id {
+			writeJSON(w, drive.File{Id: f.ID, Name: f.Title, Parents: s.fileParents(id)})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s", id))
+}
+
+func (s *Server) fileParents(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if parents, ok := s.parents[id]; ok {
+		return slices.Clone(parents)
+	}
+	return []string{"root"}
+}
+
+// updateFile поддерживает запись меток appProperties, которыми сервис отмечает свои таблицы,
+// и перенос между папками (addParents, removeParents)
+func (s *Server) updateFile(w http.ResponseWriter, r *http.Request, id string) {
+	var req drive.File
+	if !decode(w, r, &req) {
+		return
+	}
+	if add, remove := r.URL.Query().Get("addParents"), r.URL.Query().Get("removeParents"); add != "" || remove != "" {
+		parents := slices.DeleteFunc(s.fileParents(id), func(p string) bool {
+			return slices.Contains(strings.Split(remove, ","), p)
+		})
+		if add != "" {
+			parents = append(parents, strings.Split(add, ",")...)
+		}
+		s.mu.Lock()
+		s.parents[id] = parents
+		s.mu.Unlock()
+	}
+	if req.AppProperties[backend.AppPropertyManagedBy] == backend.ManagedByValue {
+		if err := s.Store.TagSpreadsheet(id, req.AppProperties[backend.AppPropertyFiat]); err != nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s", id))
+			return
+		}
+	}
+	writeJSON(w, drive.File{Id: id})
+}
+
+func (s *Server) createPermission(w http.ResponseWriter, r *http.Request, id string) {
+	var req drive.Permission
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := s.Store.AddPermission(id, []string{req.EmailAddress}); err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s", id))
+		return
+	}
+	writeJSON(w, drive.Permission{
+		Id:           fmt.Sprintf("perm-%s", req.EmailAddress),
+		Role:         req.Role,
+		Type:         req.Type,
+		EmailAddress: req.EmailAddress,
+	})
+}
+
+// exists отвечает 404, если таблицы нет
+func (s *Server) exists(w http.ResponseWriter, id string) bool {
+	if _, err := s.Store.ListSheets(id); err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Requested entity was not found.")
+		return false
+	}
+	return true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Invalid JSON payload: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError отвечает в формате ошибок Google API, который разбирает googleapi.CheckResponse
+func writeError(w http.ResponseWriter, code int, status, message string) {
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"status":  status,
+			"errors": []map[string]string{
+				{"message": message, "reason": reason(code)},
+			},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func statusName(code int) string {
+	switch code {
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
+
+func reason(code int) string {
+	switch code {
+	case http.StatusTooManyRequests:
+		return "rateLimitExceeded"
+	case http.StatusNotFound:
+		return "notFound"
+	case http.StatusBadRequest:
+		return "badRequest"
+	default:
+		return "backendError"
+	}
+}
@@ -1,24 +1,30 @@
 package googleAPI
 
 import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/apperrors"
 	"GoogleSheetW/internal/logger"
 	"context"
 	"fmt"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 var log = logger.Get()
 
 func SheetExists(srv *sheets.Service, spreadsheetID, sheetName string) (bool, error) {
 
-	spreadsheet, err := srv.Spreadsheets.Get(spreadsheetID).Do()
+	spreadsheet, err := call("spreadsheets.get", srv.Spreadsheets.Get(spreadsheetID).Do)
 	if err != nil {
-		return false, fmt.Errorf("Ошибка при получении таблицы: %v", err)
+		return false, fmt.Errorf("Ошибка при получении таблицы: %w", err)
 	}
 
 	for _, sheet := range spreadsheet.Sheets {
@@ -30,7 +36,7 @@ func SheetExists(srv *sheets.Service, spreadsheetID, sheetName string) (bool, er
 }
 
 func SheetIDByName(srv *sheets.Service, spreadsheetID, name string) (int64, error) {
-	resp, err := srv.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties").Do()
+	resp, err := call("spreadsheets.get", srv.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties").Do)
 	if err != nil {
 		return 0, err
 	}
@@ -39,61 +45,290 @@ func SheetIDByName(srv *sheets.Service, spreadsheetID, name string) (int64, erro
 			return s.Properties.SheetId, nil
 		}
 	}
-	return 0, fmt.Errorf("лист с названием %q не найден", name)
+	return 0, fmt.Errorf("лист с названием %q не найден: %w", name, apperrors.ErrSheetNotFound)
 }
 
-func CreateSheetList(srv *sheets.Service, spreadsheetID, sheetName string) error {
-	request := &sheets.AddSheetRequest{
-		Properties: &sheets.SheetProperties{
-			Title: sheetName,
-		},
-	}
-	batchUpdateRequest := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{
-			{
-				AddSheet: request,
-			},
-		},
+// BatchUpdate выполняет запросы одним spreadsheets.batchUpdate; Google применяет их атомарно
+func BatchUpdate(srv *sheets.Service, spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	batchUpdateRequest := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	resp, err := callThrottled("spreadsheets.batchUpdate", srv.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateRequest).Do)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось изменить таблицу: %w", err)
 	}
+	log.Infow("Таблица изменена", "spreadsheet_id", spreadsheetID, "requests", len(requests))
+	return resp.Replies, nil
+}
 
-	_, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, batchUpdateRequest).Do()
+// GetSheetsProperties возвращает свойства всех листов таблицы
+func GetSheetsProperties(srv *sheets.Service, spreadsheetID string) ([]*sheets.SheetProperties, error) {
+	resp, err := call("spreadsheets.get", srv.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties").Do)
 	if err != nil {
-		return fmt.Errorf("Не удалось создать лист: %v", err)
+		return nil, fmt.Errorf("Ошибка при получении таблицы: %w", err)
 	}
-	log.Infow("Лист успешно создан", "sheet_name", sheetName, "spreadsheet_id", spreadsheetID)
-	return nil
+	properties := make([]*sheets.SheetProperties, 0, len(resp.Sheets))
+	for _, s := range resp.Sheets {
+		properties = append(properties, s.Properties)
+	}
+	return properties, nil
 }
 
 func WriteToSheet(srv *sheets.Service, spreadsheetID string, data []*sheets.ValueRange) error {
-	_, err := srv.Spreadsheets.Values.BatchUpdate(spreadsheetID,
+	_, err := call("values.batchUpdate", srv.Spreadsheets.Values.BatchUpdate(spreadsheetID,
 		&sheets.BatchUpdateValuesRequest{ValueInputOption: "USER_ENTERED",
-			Data: data}).Do()
+			Data: data}).Do)
 	if err != nil {
-		return fmt.Errorf("Не удалось записать данные: %v", err)
+		return fmt.Errorf("Не удалось записать данные: %w", err)
 	}
 	return nil
 }
 
-func DeleteFromSheet(srv *sheets.Service, spreadsheetID string, ranges []string) error {
-	_, err := srv.Spreadsheets.Values.BatchClear(spreadsheetID, &sheets.BatchClearValuesRequest{Ranges: ranges}).Do()
+// AppendToSheet дописывает строки под таблицей в диапазоне rng (values.append с INSERT_ROWS). Повтор вызова
+// дописал бы строки второй раз, поэтому ошибки после отправки запроса не повторяются
+func AppendToSheet(srv *sheets.Service, spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	resp, err := callThrottled("values.append", srv.Spreadsheets.Values.Append(spreadsheetID, rng, &sheets.ValueRange{Values: rows}).
+		ValueInputOption("USER_ENTERED").
+		InsertDataOption("INSERT_ROWS").Do)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось дописать данные: %w", err)
+	}
+	if resp.Updates == nil {
+		return "", nil
+	}
+	return resp.Updates.UpdatedRange, nil
+}
+
+// ReadSheet возвращает значения листа. Числа читаются без форматирования (не зависят от локали таблицы),
+// даты - так, как они показаны в таблице
+func ReadSheet(srv *sheets.Service, spreadsheetID, sheetName string) ([][]string, error) {
+	resp, err := call("values.get", srv.Spreadsheets.Values.Get(spreadsheetID, a1Notation.QuoteSheet(sheetName)).
+		ValueRenderOption("UNFORMATTED_VALUE").
+		DateTimeRenderOption("FORMATTED_STRING").Do)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось прочитать лист %s: %w", sheetName, err)
+	}
+	values := make([][]string, len(resp.Values))
+	for i, row := range resp.Values {
+		values[i] = make([]string, len(row))
+		for j, value := range row {
+			switch v := value.(type) {
+			case float64:
+				values[i][j] = strconv.FormatFloat(v, 'f', -1, 64)
+			case nil:
+			default:
+				values[i][j] = fmt.Sprint(v)
+			}
+		}
+	}
+	return values, nil
+}
+
+const (
+	spreadsheetMimeType = "application/vnd.google-apps.spreadsheet"
+	folderMimeType      = "application/vnd.google-apps.folder"
+
+	listPageSize = 1000 // максимальный размер страницы Files.List
+	// parentsPerQuery ограничивает число папок в одном запросе, чтобы не превысить длину запроса
+	parentsPerQuery = 50
+)
+
+// quote экранирует строку для запроса Drive
+func quote(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	return "'" + strings.ReplaceAll(value, "'", "\\'") + "'"
+}
+
+// filesQuery формирует запрос Drive на файлы типа mimeType не из корзины, лежащие в одной из папок parents.
+// Пустой parents означает поиск по всему Drive
+func filesQuery(mimeType string, parents []string) string {
+	query := fmt.Sprintf("mimeType=%s and trashed=false", quote(mimeType))
+	if len(parents) == 0 {
+		return query
+	}
+	conditions := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		conditions = append(conditions, quote(parent)+" in parents")
+	}
+	return query + " and (" + strings.Join(conditions, " or ") + ")"
+}
+
+// listFiles проходит все страницы Files.List. Файлы ищутся на общем диске driveID, а если он не задан -
+// среди файлов пользователя: поиск по всем общим дискам медленнее и может вернуть неполный список.
+// Если Drive всё же сообщает о неполном поиске (IncompleteSearch), в лог пишется предупреждение
+// и возвращается то, что найдено
+func listFiles(srv *drive.Service, driveID, query, fields string) ([]*drive.File, error) {
+	var files []*drive.File
+	pageToken := ""
+	for {
+		list := srv.Files.List().
+			Q(query).
+			Fields(googleapi.Field("nextPageToken, incompleteSearch, files(" + fields + ")")).
+			PageSize(listPageSize).
+			SupportsAllDrives(true).
+			IncludeItemsFromAllDrives(true)
+		if driveID != "" {
+			list = list.Corpora("drive").DriveId(driveID)
+		} else {
+			list = list.Corpora("user")
+		}
+		if pageToken != "" {
+			list = list.PageToken(pageToken)
+		}
+		page, err := call("files.list", list.Do)
+		if err != nil {
+			return nil, fmt.Errorf("Ошибка получения списка файлов: %w", err)
+		}
+		if page.IncompleteSearch {
+			log.Warnw("Drive вернул неполный список файлов", "query", query, "drive_id", driveID)
+		}
+		files = append(files, page.Files...)
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// FolderDriveID возвращает ID общего диска, на котором лежит папка; пусто - папка не на общем диске
+func FolderDriveID(srv *drive.Service, folderID string) (string, error) {
+	folder, err := call("files.get", srv.Files.Get(folderID).Fields("driveId").SupportsAllDrives(true).Do)
 	if err != nil {
-		return fmt.Errorf("Не удалось очистить данные: %v", err)
+		return "", fmt.Errorf("Не удалось получить папку %s: %w", folderID, err)
+	}
+	return folder.DriveId, nil
+}
+
+// ListSpreadsheets возвращает все таблицы, доступные сервисному аккаунту, или таблицы общего диска driveID.
+// Если заданы folderIDs, возвращаются только таблицы, лежащие в этих папках
+func ListSpreadsheets(srv *drive.Service, driveID string, folderIDs []string) ([]*drive.File, error) {
+	if len(folderIDs) == 0 {
+		return listFiles(srv, driveID, filesQuery(spreadsheetMimeType, nil), "id, name, appProperties")
+	}
+	var files []*drive.File
+	for chunk := range slices.Chunk(folderIDs, parentsPerQuery) {
+		page, err := listFiles(srv, driveID, filesQuery(spreadsheetMimeType, chunk), "id, name, appProperties")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
 	}
+	return files, nil
+}
+
+// ListFolderTree возвращает ID папки rootID и всех вложенных в неё папок; driveID - общий диск папки rootID
+func ListFolderTree(srv *drive.Service, driveID, rootID string) ([]string, error) {
+	tree := []string{rootID}
+	level := []string{rootID}
+	for len(level) > 0 {
+		var next []string
+		for chunk := range slices.Chunk(level, parentsPerQuery) {
+			folders, err := listFiles(srv, driveID, filesQuery(folderMimeType, chunk), "id")
+			if err != nil {
+				return nil, err
+			}
+			for _, folder := range folders {
+				if !slices.Contains(tree, folder.Id) {
+					next = append(next, folder.Id)
+				}
+			}
+		}
+		tree = append(tree, next...)
+		level = next
+	}
+	return tree, nil
+}
+
+// EnsureFolder возвращает ID папки name внутри parentID, создавая её при отсутствии; driveID - общий диск parentID
+func EnsureFolder(srv *drive.Service, driveID, parentID, name string) (string, error) {
+	query := filesQuery(folderMimeType, []string{parentID}) + " and name=" + quote(name)
+	folders, err := listFiles(srv, driveID, query, "id")
+	if err != nil {
+		return "", err
+	}
+	if len(folders) > 0 {
+		return folders[0].Id, nil
+	}
+
+	// Создание папки не идемпотентно: при 5xx повтор мог бы создать вторую папку с тем же именем
+	folder, err := callThrottled("files.create", srv.Files.Create(&drive.File{
+		Name:     name,
+		MimeType: folderMimeType,
+		Parents:  []string{parentID},
+	}).Fields("id").SupportsAllDrives(true).Do)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось создать папку %s: %w", name, err)
+	}
+
+	log.Infow("Папка создана", "name", name, "parent_id", parentID, "folder_id", folder.Id)
+	return folder.Id, nil
+}
+
+// MoveToFolder переносит файл в папку, убирая его из прежних папок
+func MoveToFolder(srv *drive.Service, fileID, folderID string) error {
+	file, err := call("files.get", srv.Files.Get(fileID).Fields("parents").SupportsAllDrives(true).Do)
+	if err != nil {
+		return fmt.Errorf("Не удалось получить папки файла %s: %w", fileID, err)
+	}
+	_, err = call("files.update", srv.Files.Update(fileID, &drive.File{}).
+		AddParents(folderID).
+		RemoveParents(strings.Join(file.Parents, ",")).
+		Fields("id").
+		SupportsAllDrives(true).
+		Do)
+	if err != nil {
+		return fmt.Errorf("Не удалось перенести файл %s в папку %s: %w", fileID, folderID, err)
+	}
+
+	log.Infow("Файл перенесён в папку", "file_id", fileID, "folder_id", folderID)
 	return nil
 }
 
-func GetAllSheetIDByName(srv *drive.Service) (map[string]string, error) {
-	files, err := srv.Files.List().Q("mimeType='application/vnd.google-apps.spreadsheet'").Fields("files(id, name)").Do()
+// GetAllSheetIDByName возвращает ID таблиц, у которых есть метка managedKey=managedValue,
+// по значению метки fiatKey. Если задана папка folderID, таблицы ищутся только в её дереве.
+// Остальные таблицы, доступные сервисному аккаунту, пропускаются
+func GetAllSheetIDByName(srv *drive.Service, folderID, managedKey, managedValue, fiatKey string) (map[string]string, error) {
+	var driveID string
+	var folderIDs []string
+	if folderID != "" {
+		var err error
+		if driveID, err = FolderDriveID(srv, folderID); err != nil {
+			return nil, err
+		}
+		tree, err := ListFolderTree(srv, driveID, folderID)
+		if err != nil {
+			return nil, err
+		}
+		folderIDs = tree
+	}
+	files, err := ListSpreadsheets(srv, driveID, folderIDs)
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка получения списка файлов: %v", err)
+		return nil, err
 	}
 	sheetID := make(map[string]string)
-	for _, file := range files.Files {
-		sheetID[file.Name] = file.Id
+	for _, file := range files {
+		if file.AppProperties[managedKey] != managedValue || file.AppProperties[fiatKey] == "" {
+			continue
+		}
+		if existing, ok := sheetID[file.AppProperties[fiatKey]]; ok {
+			log.Warnw("Найдено несколько таблиц для одной валюты",
+				"fiat", file.AppProperties[fiatKey],
+				"spreadsheet_id", existing,
+				"duplicate_id", file.Id)
+			continue
+		}
+		sheetID[file.AppProperties[fiatKey]] = file.Id
 	}
 	return sheetID, nil
 }
 
+// SetAppProperties записывает метки appProperties файла в Drive
+func SetAppProperties(srv *drive.Service, fileID string, properties map[string]string) error {
+	_, err := call("files.update", srv.Files.Update(fileID, &drive.File{AppProperties: properties}).Fields("id").SupportsAllDrives(true).Do)
+	if err != nil {
+		return fmt.Errorf("Не удалось отметить файл %s: %w", fileID, err)
+	}
+	return nil
+}
+
 func CreateSheet(srv *sheets.Service, sheetName string) (string, error) {
 	sheet := &sheets.Spreadsheet{
 		Properties: &sheets.SpreadsheetProperties{
@@ -102,15 +337,32 @@ func CreateSheet(srv *sheets.Service, sheetName string) (string, error) {
 			TimeZone:   "GMT+00:00",
 		},
 	}
-	spreadsheet, err := srv.Spreadsheets.Create(sheet).Do()
+	spreadsheet, err := callThrottled("spreadsheets.create", srv.Spreadsheets.Create(sheet).Do)
 	if err != nil {
-		return "", fmt.Errorf("Не удалось создать таблицу: %v", err)
+		return "", fmt.Errorf("Не удалось создать таблицу: %w", err)
 	}
 
 	log.Infow("Таблица успешно создана", "sheet_name", sheetName, "spreadsheet_id", spreadsheet.SpreadsheetId)
 	return spreadsheet.SpreadsheetId, nil
 }
 
+// CopySpreadsheet создаёт таблицу title копией шаблона templateID; если задан parentID,
+// копия сразу создаётся в этой папке
+func CopySpreadsheet(srv *drive.Service, templateID, title, parentID string) (string, error) {
+	file := &drive.File{Name: title}
+	if parentID != "" {
+		file.Parents = []string{parentID}
+	}
+	// Копия не идемпотентна: при 5xx повтор мог бы создать вторую таблицу
+	copied, err := callThrottled("files.copy", srv.Files.Copy(templateID, file).Fields("id").SupportsAllDrives(true).Do)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось скопировать шаблон %s: %w", templateID, err)
+	}
+
+	log.Infow("Таблица создана из шаблона", "sheet_name", title, "template_id", templateID, "spreadsheet_id", copied.Id)
+	return copied.Id, nil
+}
+
 func AddPermission(srv *drive.Service, fileID string, emails *[]string) error {
 	for _, mail := range *emails {
 		permission := &drive.Permission{
@@ -118,13 +370,15 @@ func AddPermission(srv *drive.Service, fileID string, emails *[]string) error {
 			Type:         "user",
 			EmailAddress: mail,
 		}
-		_, err := srv.Permissions.Create(fileID, permission).
+		// Повтор при 5xx отправил бы второе письмо с уведомлением о доступе
+		_, err := callThrottled("permissions.create", srv.Permissions.Create(fileID, permission).
+			SupportsAllDrives(true).
 			SendNotificationEmail(true).
 			EmailMessage("Вам предоставлен доступ к новой Google Sheets таблице для анализа данных.").
-			Do()
+			Do)
 		if err != nil {
 			log.Errorw("Не удалось добавить разрешение", "file_id", fileID, "email", mail, "error", err)
-			return fmt.Errorf("Не удалось добавить разрешение: %v", err)
+			return fmt.Errorf("Не удалось добавить разрешение: %w", err)
 		}
 		log.Infow("Разрешение успешно добавлено", "file_id", fileID, "email", mail, "role", "writer", "notification_sent", true)
 	}
@@ -151,66 +405,49 @@ func GetCredentials(ctx context.Context, pathToCredentialsFile string) (*google.
 	defer file.Close()
 	dataFile, err := io.ReadAll(file)
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка чтения файла: ", err)
+		return nil, fmt.Errorf("Ошибка чтения файла: %v", err)
 	}
 	cred, err := google.CredentialsFromJSON(ctx, dataFile,
 		drive.DriveScope,
 		drive.DriveFileScope,
 		sheets.SpreadsheetsScope)
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка загрузки учетных данных: ", err)
+		return nil, fmt.Errorf("Ошибка загрузки учетных данных: %v", err)
 	}
 	return cred, nil
 }
 
-func GetDriveService(ctx context.Context, cred *google.Credentials) (*drive.Service, error) {
-	driveSrv, err := drive.NewService(ctx, option.WithCredentials(cred))
-	if err != nil {
-		return nil, fmt.Errorf("Ошибка подключения к Google Drive API: %v", err)
+// clientOptions формирует опции клиента. Если задан endpoint, запросы уходят на него без авторизации
+// (например, на локальную замену Google API в интеграционных тестах)
+func clientOptions(cred *google.Credentials, endpoint string) []option.ClientOption {
+	if endpoint == "" {
+		return []option.ClientOption{option.WithCredentials(cred)}
 	}
-	return driveSrv, nil
+	return []option.ClientOption{option.WithEndpoint(endpoint), option.WithoutAuthentication()}
 }
 
-func GetSheetsService(ctx context.Context, cred *google.Credentials) (*sheets.Service, error) {
-	sheetsSrv, err := sheets.NewService(ctx, option.WithCredentials(cred))
+// GetDriveService создаёт клиента Drive API. endpoint - корень сервера без пути "drive/v3/", пустая строка означает Google
+func GetDriveService(ctx context.Context, cred *google.Credentials, endpoint string) (*drive.Service, error) {
+	if endpoint != "" {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/drive/v3/"
+	}
+	driveSrv, err := drive.NewService(ctx, clientOptions(cred, endpoint)...)
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка подключения к Google Sheets API: %v", err)
+		return nil, fmt.Errorf("Ошибка подключения к Google Drive API: %v", err)
 	}
-	return sheetsSrv, nil
+	return driveSrv, nil
 }
 
-func CreateSheetFilter(srv *sheets.Service, spreadsheetID string, sheetID int64, startRowIndex, endRowIndex, startColumnIndex, endColumnIndex int64) error {
-	request := &sheets.BatchUpdateSpreadsheetRequest{
-		Requests: []*sheets.Request{
-			{
-				SetBasicFilter: &sheets.SetBasicFilterRequest{
-					Filter: &sheets.BasicFilter{
-						Range: &sheets.GridRange{
-							SheetId:          sheetID,
-							StartRowIndex:    startRowIndex,
-							EndRowIndex:      endRowIndex,
-							StartColumnIndex: startColumnIndex,
-							EndColumnIndex:   endColumnIndex,
-						},
-					},
-				},
-			},
-		},
+// GetSheetsService создаёт клиента Sheets API. endpoint - корень сервера, пустая строка означает Google
+func GetSheetsService(ctx context.Context, cred *google.Credentials, endpoint string) (*sheets.Service, error) {
+	if endpoint != "" {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/"
 	}
-
-	_, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, request).Do()
+	sheetsSrv, err := sheets.NewService(ctx, clientOptions(cred, endpoint)...)
 	if err != nil {
-		return fmt.Errorf("не удалось создать фильтр: %v", err)
+		return nil, fmt.Errorf("Ошибка подключения к Google Sheets API: %v", err)
 	}
-
-	log.Infow("Базовый фильтр успешно создан",
-		"spreadsheet_id", spreadsheetID,
-		"sheet_id", sheetID,
-		"start_row", startRowIndex,
-		"end_row", endRowIndex,
-		"start_column", startColumnIndex,
-		"end_column", endColumnIndex)
-	return nil
+	return sheetsSrv, nil
 }
 
 // DeleteSheetByName удаляет лист из таблицы по имени
@@ -218,7 +455,7 @@ func DeleteSheetByName(srv *sheets.Service, spreadsheetID, sheetName string) err
 	// Получаем ID листа по имени
 	sheetID, err := SheetIDByName(srv, spreadsheetID, sheetName)
 	if err != nil {
-		return fmt.Errorf("не удалось найти лист %s: %v", sheetName, err)
+		return fmt.Errorf("не удалось найти лист %s: %w", sheetName, err)
 	}
 
 	// Создаем запрос на удаление листа
@@ -233,9 +470,9 @@ func DeleteSheetByName(srv *sheets.Service, spreadsheetID, sheetName string) err
 	}
 
 	// Выполняем запрос
-	_, err = srv.Spreadsheets.BatchUpdate(spreadsheetID, request).Do()
+	_, err = call("spreadsheets.batchUpdate", srv.Spreadsheets.BatchUpdate(spreadsheetID, request).Do)
 	if err != nil {
-		return fmt.Errorf("не удалось удалить лист %s: %v", sheetName, err)
+		return fmt.Errorf("не удалось удалить лист %s: %w", sheetName, err)
 	}
 
 	log.Infow("Лист успешно удален", "sheet_name", sheetName, "spreadsheet_id", spreadsheetID)
@@ -244,9 +481,9 @@ func DeleteSheetByName(srv *sheets.Service, spreadsheetID, sheetName string) err
 
 // DeleteSpreadsheetByID удаляет всю таблицу по ID
 func DeleteSpreadsheetByID(driveSrv *drive.Service, spreadsheetID string) error {
-	err := driveSrv.Files.Delete(spreadsheetID).Do()
+	err := callNoResult("files.delete", driveSrv.Files.Delete(spreadsheetID).SupportsAllDrives(true).Do)
 	if err != nil {
-		return fmt.Errorf("не удалось удалить таблицу %s: %v", spreadsheetID, err)
+		return fmt.Errorf("не удалось удалить таблицу %s: %w", spreadsheetID, err)
 	}
 
 	log.Infow("Таблица успешно удалена", "spreadsheet_id", spreadsheetID)
@@ -0,0 +1,206 @@
+package googleAPI
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy задаёт правила повтора запросов к Google API
+type RetryPolicy struct {
+	MaxAttempts  int           // общее число попыток, включая первую
+	InitialDelay time.Duration // пауза перед первым повтором
+	MaxDelay     time.Duration // верхняя граница паузы между попытками
+}
+
+var (
+	retryPolicy = RetryPolicy{MaxAttempts: 5, InitialDelay: time.Second, MaxDelay: 32 * time.Second}
+	retryCtx    = context.Background()
+	retryMu     sync.RWMutex
+)
+
+// SetRetryPolicy устанавливает политику повторов для всех вызовов пакета
+func SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxDelay < policy.InitialDelay {
+		policy.MaxDelay = policy.InitialDelay
+	}
+
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicy = policy
+}
+
+// SetRetryContext задаёт контекст, отмена которого прерывает ожидание между повторами,
+// например при остановке сервиса
+func SetRetryContext(ctx context.Context) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryCtx = ctx
+}
+
+func getRetryPolicy() (RetryPolicy, context.Context) {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return retryPolicy, retryCtx
+}
+
+// call выполняет запрос (метод Do сгенерированного клиента) с повторами при временных ошибках
+func call[T any](op string, do func(...googleapi.CallOption) (T, error)) (T, error) {
+	var result T
+	err := retry(op, isRetryable, func() error {
+		var err error
+		result, err = do()
+		return err
+	})
+	return result, err
+}
+
+// callThrottled повторяет запрос только при превышении квоты: при 5xx неидемпотентный запрос
+// (например, создание таблицы) мог быть выполнен, и повтор создал бы дубликат
+func callThrottled[T any](op string, do func(...googleapi.CallOption) (T, error)) (T, error) {
+	var result T
+	err := retry(op, isThrottled, func() error {
+		var err error
+		result, err = do()
+		return err
+	})
+	return result, err
+}
+
+// callNoResult выполняет с повторами запрос, который не возвращает данных (например, удаление файла)
+func callNoResult(op string, do func(...googleapi.CallOption) error) error {
+	return retry(op, isRetryable, func() error {
+		return do()
+	})
+}
+
+func retry(op string, retryable func(error) bool, fn func() error) error {
+	policy, ctx := getRetryPolicy()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !retryable(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		// Retry-After учитывается, но не дольше MaxDelay: иначе сервер мог бы задержать запрос на любой срок
+		delay := backoff(policy, attempt)
+		if retryAfter := retryAfterDelay(err); retryAfter > delay {
+			delay = min(retryAfter, policy.MaxDelay)
+		}
+		log.Warnw("Временная ошибка Google API, повтор запроса",
+			"operation", op,
+			"attempt", attempt,
+			"max_attempts", policy.MaxAttempts,
+			"delay", delay,
+			"error", err)
+		if !sleep(ctx, delay) {
+			return fmt.Errorf("повтор запроса %s прерван: %w", op, err)
+		}
+	}
+}
+
+// sleep ждёт delay; возвращает false, если ожидание прервано отменой ctx
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// backoff возвращает экспоненциальную паузу; случайный разброс в половину паузы разводит одновременные повторы
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.InitialDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, policy.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isRetryable сообщает, имеет ли смысл повторить запрос: превышение квоты, ошибки сервера и сети
+func isRetryable(err error) bool {
+	if isThrottled(err) {
+		return true
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		switch gerr.Code {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return hasReason(gerr, "backendError", "internalError")
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isThrottled сообщает, отклонён ли запрос из-за превышения квоты
+func isThrottled(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	return gerr.Code == http.StatusForbidden && hasReason(gerr, "rateLimitExceeded", "userRateLimitExceeded")
+}
+
+func hasReason(gerr *googleapi.Error, reasons ...string) bool {
+	for _, item := range gerr.Errors {
+		for _, reason := range reasons {
+			if item.Reason == reason {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryAfterDelay читает заголовок Retry-After (секунды или HTTP-дата)
+func retryAfterDelay(err error) time.Duration {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Header == nil {
+		return 0
+	}
+	value := gerr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, parseErr := http.ParseTime(value); parseErr == nil {
+		return time.Until(at)
+	}
+	return 0
+}
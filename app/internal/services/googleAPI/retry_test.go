@@ -0,0 +1,65 @@
+package googleAPI
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/googleapi"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func throttledError(retryAfter string) error {
+	header := http.Header{}
+	header.Set("Retry-After", retryAfter)
+	return &googleapi.Error{Code: http.StatusTooManyRequests, Header: header}
+}
+
+func TestRetryClampsRetryAfterToMaxDelay(t *testing.T) {
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	SetRetryContext(context.Background())
+	t.Cleanup(func() { SetRetryPolicy(RetryPolicy{MaxAttempts: 1}) })
+
+	attempts := 0
+	start := time.Now()
+	err := retry("test", isRetryable, func() error {
+		attempts++
+		if attempts == 1 {
+			return throttledError("3600")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Retry-After не ограничен MaxDelay: ожидание %s", elapsed)
+	}
+}
+
+func TestRetryStopsWhenContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour, MaxDelay: time.Hour})
+	SetRetryContext(ctx)
+	t.Cleanup(func() {
+		SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+		SetRetryContext(context.Background())
+	})
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	cause := throttledError("1")
+	attempts := 0
+	err := retry("test", isRetryable, func() error {
+		attempts++
+		return cause
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("err = %v, want wrapped %v", err, cause)
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+}
@@ -0,0 +1,290 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/models"
+	"GoogleSheetW/internal/outbox"
+	"errors"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rawTimeLayouts - форматы времени в столбце RawTimeColumn; время без часового пояса считается местным
+var rawTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// appendRaw дописывает строки raw под данными листа RAW через values.append и удаляет строки вне окна
+// (DeleteDimension). Возвращает false, если листа RAW ещё нет: тогда он создаётся обычной записью.
+// values.append не идемпотентен, поэтому перед отправкой строк число строк листа сохраняется в журнале
+// под номером seq: повтор по нему узнаёт, дописаны ли строки, и не дописывает их второй раз.
+// После дописывания строк запись не отменяется, а ошибки удаления строк и обновления кэша только
+// записываются в лог. Если RawMaxRows задан, дозапись не переносит строки на RAW_2 и отклоняется,
+// когда они не помещаются на лист RAW
+func (sc *SheetsControl) appendRaw(b backend.SpreadsheetBackend, s *saga, spreadsheetID, fiat string, raw models.RAWData, seq uint64) (bool, error) {
+	var current models.RAWData
+	missing := false
+	err := s.run(StepReadRaw, func() error {
+		rows, err := b.ReadValues(spreadsheetID, "RAW")
+		if errors.Is(err, apperrors.ErrSheetNotFound) {
+			missing = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sc.layout.Raw.Parse(rows, &current); err != nil {
+			return err
+		}
+		applied, err := sc.appendApplied(seq, len(current.Data))
+		if applied {
+			sc.log.Infow("Строки RAW уже дописаны предыдущей попыткой", "fiat", fiat, "seq", seq)
+			raw.Data = nil
+		}
+		return err
+	})
+	if err != nil || missing {
+		return false, err
+	}
+
+	// Окно считается по всем строкам вместе с новыми: новые строки вне окна не дописываются
+	combined := append(current.Data[:len(current.Data):len(current.Data)], raw.Data...)
+	start := sc.rawWindowStart(combined, time.Now())
+	trimmed := min(start, len(current.Data))
+	appendRows := raw.Data[max(start-len(current.Data), 0):]
+
+	var values []*sheets.ValueRange
+	var table string
+	var rows [][]interface{}
+	err = s.run(StepPlanWrite, func() (err error) {
+		if err := sc.checkAppendCapacity(fiat, len(current.Data)-trimmed+len(appendRows)); err != nil {
+			return err
+		}
+		values, table, rows, err = sc.layout.Raw.AppendValues("RAW", models.RAWData{Date: raw.Date, Data: appendRows})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(values) > 0 {
+		err = s.run(StepWriteValues, func() error {
+			return b.WriteValues(spreadsheetID, values)
+		})
+		if err != nil {
+			return false, err
+		}
+		// Прежние значения вне таблицы перезаписаны и не восстанавливаются
+		s.irreversibleChange()
+	}
+	if len(rows) > 0 {
+		err = s.run(StepAppendValues, func() error {
+			if sc.outbox != nil && seq != 0 {
+				mark := outbox.AppendMark{Base: len(current.Data), Rows: len(rows)}
+				if err := sc.outbox.MarkAppend(seq, mark); err != nil {
+					return err
+				}
+			}
+			_, err := b.AppendValues(spreadsheetID, table, rows)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	s.commit()
+
+	state, known, err := sc.cache.GetSheetState(fiat, "RAW")
+	if err != nil {
+		known = false
+	}
+	if trimmed > 0 {
+		if err := sc.trimRawRows(b, spreadsheetID, state.SheetID, table, trimmed); err != nil {
+			sc.log.Warnw("Ошибка удаления строк RAW вне окна", "fiat", fiat, "rows", trimmed, "error", err)
+			trimmed = 0
+		}
+	}
+	sc.log.Infow("Строки RAW дописаны", "fiat", fiat, "appended", len(rows), "trimmed", trimmed)
+
+	if cached, err := sc.cache.IsSupInCashed(fiat, "RAW"); err == nil && !cached {
+		if err := sc.cache.SetSupInCashed(fiat, "RAW"); err != nil {
+			sc.log.Warnw("Ошибка добавления листа в кэш", "fiat", fiat, "sheetName", "RAW", "error", err)
+		}
+	}
+	// Область таблицы в кэше - все строки после дозаписи, чтобы обычная запись RAW очистила их целиком
+	tableRows := append(current.Data[trimmed:len(current.Data):len(current.Data)], appendRows...)
+	_, extents := sc.layout.Raw.Values("RAW", models.RAWData{Date: raw.Date, Data: tableRows})
+	grids := map[string]gridSize{}
+	if known && state.GridRows > 0 {
+		grids["RAW"] = gridSize{rows: state.GridRows + int64(len(rows)-trimmed), cols: state.GridCols}
+	}
+	sheetIDs := map[string]int64{}
+	if state.SheetID != nil {
+		sheetIDs["RAW"] = *state.SheetID
+	}
+	sc.saveSheetStates(fiat, map[string][]string{"RAW": extents}, grids, sheetIDs)
+	sc.growRawFilter(b, spreadsheetID, fiat, len(tableRows))
+	return true, nil
+}
+
+// growRawFilter увеличивает сетку RAW_filter под результат формулы для rows строк данных. Строки уже
+// дописаны, поэтому ошибки только записываются в лог: сетка увеличится при следующей записи
+func (sc *SheetsControl) growRawFilter(b backend.SpreadsheetBackend, spreadsheetID, fiat string, rows int) {
+	need := sc.rawFilterNeed(rows)
+	if need.rows == 0 {
+		return
+	}
+	state, known, err := sc.cache.GetSheetState(fiat, "RAW_filter")
+	grid := gridSize{rows: state.GridRows, cols: state.GridCols}
+	if err == nil && known && grid.fits(need) {
+		return
+	}
+	var sheetID int64
+	if err == nil && known && state.SheetID != nil && state.GridRows > 0 {
+		sheetID = *state.SheetID
+	} else {
+		sheetList, err := b.ListSheets(spreadsheetID)
+		if err != nil {
+			sc.log.Warnw("Ошибка получения листов таблицы для увеличения RAW_filter", "fiat", fiat, "error", err)
+			return
+		}
+		i := slices.IndexFunc(sheetList, func(sheet backend.SheetInfo) bool { return sheet.Title == "RAW_filter" })
+		if i < 0 {
+			return
+		}
+		sheetID = sheetList[i].SheetID
+		grid = gridSize{rows: sheetList[i].RowCount, cols: sheetList[i].ColumnCount}
+	}
+	if requests := appendDimensionRequests(sheetID, grid, need); len(requests) > 0 {
+		if _, err := b.BatchUpdate(spreadsheetID, requests); err != nil {
+			sc.log.Warnw("Ошибка увеличения сетки RAW_filter", "fiat", fiat, "rows", need.rows, "error", err)
+			return
+		}
+	}
+	sc.saveSheetStates(fiat, nil, map[string]gridSize{"RAW_filter": grid.grow(need)}, map[string]int64{"RAW_filter": sheetID})
+}
+
+// appendApplied сверяет число строк листа RAW с сохранённым в журнале перед прошлой попыткой дозаписи
+// данных seq: true - строки уже дописаны. Если число строк не совпадает ни с состоянием до дозаписи,
+// ни с состоянием после неё, лист изменён иначе, и строки не дописываются
+func (sc *SheetsControl) appendApplied(seq uint64, rows int) (bool, error) {
+	if sc.outbox == nil || seq == 0 {
+		return false, nil
+	}
+	mark, ok := sc.outbox.AppendMarkOf(seq)
+	if !ok {
+		return false, nil
+	}
+	switch rows {
+	case mark.Base:
+		return false, nil
+	case mark.Base + mark.Rows:
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: до дозаписи было %d строк, дописывалось %d, сейчас %d",
+			apperrors.ErrAppendConflict, mark.Base, mark.Rows, rows)
+	}
+}
+
+// checkAppendCapacity отклоняет дозапись, после которой на листе RAW было бы больше RawMaxRows строк
+// или строки уже перенесены на RAW_2: дописанные строки нарушили бы порядок строк между листами
+func (sc *SheetsControl) checkAppendCapacity(fiat string, rows int) error {
+	if sc.rawMaxRows <= 0 {
+		return nil
+	}
+	sups, err := sc.cache.GetSupsByFiat(fiat)
+	if err != nil {
+		return err
+	}
+	for _, name := range sups {
+		if rawSheetPattern.MatchString(name) {
+			return &apperrors.ValidationError{Field: "data",
+				Msg: fmt.Sprintf("строки RAW перенесены на лист %s: дозапись невозможна, запишите RAW с заменой", name)}
+		}
+	}
+	if rows > sc.rawMaxRows {
+		return &apperrors.ValidationError{Field: "data",
+			Msg: fmt.Sprintf("на листе RAW было бы %d строк при RAW_MAX_ROWS=%d: дозапись невозможна, запишите RAW с заменой", rows, sc.rawMaxRows)}
+	}
+	return nil
+}
+
+// trimRawRows удаляет первые count строк таблицы RAW, начинающейся в области table
+func (sc *SheetsControl) trimRawRows(b backend.SpreadsheetBackend, spreadsheetID string, sheetID *int64, table string, count int) error {
+	r, err := a1Notation.Parse(table)
+	if err != nil {
+		return err
+	}
+	var id int64
+	if sheetID != nil {
+		id = *sheetID
+	} else if id, err = b.SheetIDByName(spreadsheetID, "RAW"); err != nil {
+		return err
+	}
+	_, err = b.BatchUpdate(spreadsheetID, []*sheets.Request{{DeleteDimension: &sheets.DeleteDimensionRequest{
+		Range: &sheets.DimensionRange{
+			SheetId:    id,
+			Dimension:  "ROWS",
+			StartIndex: int64(r.StartRow),
+			EndIndex:   int64(r.StartRow + count),
+		},
+	}}})
+	return err
+}
+
+// rawWindowStart возвращает индекс первой строки, которая остаётся в окне: не больше RawWindowRows последних
+// строк и не старше RawWindowAge. Строки идут по возрастанию времени, поэтому удаляются только первые;
+// строка с нераспознанным временем останавливает удаление по возрасту
+func (sc *SheetsControl) rawWindowStart(rows [][]string, now time.Time) int {
+	start := 0
+	if sc.rawWindowRows > 0 && len(rows) > sc.rawWindowRows {
+		start = len(rows) - sc.rawWindowRows
+	}
+	if sc.rawWindowAge <= 0 {
+		return start
+	}
+	cutoff := now.Add(-sc.rawWindowAge)
+	for ; start < len(rows); start++ {
+		if sc.rawTimeColumn < 0 || sc.rawTimeColumn >= len(rows[start]) {
+			break
+		}
+		t, ok := parseRawTime(rows[start][sc.rawTimeColumn])
+		if !ok || !t.Before(cutoff) {
+			break
+		}
+	}
+	return start
+}
+
+// parseRawTime разбирает время строки RAW: дату в одном из rawTimeLayouts или Unix-время в секундах
+// или миллисекундах
+func parseRawTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch {
+		case n >= 1e12:
+			return time.UnixMilli(n), true
+		case n >= 1e9:
+			return time.Unix(n, 0), true
+		}
+		return time.Time{}, false
+	}
+	for _, layout := range rawTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
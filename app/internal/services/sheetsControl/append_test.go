@@ -0,0 +1,129 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/models"
+	"GoogleSheetW/internal/outbox"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// lostAppendBackend дописывает строки, но возвращает ошибку, как при таймауте ответа Google
+type lostAppendBackend struct {
+	backend.SpreadsheetBackend
+	lose    atomic.Bool
+	appends atomic.Int32
+}
+
+func (l *lostAppendBackend) AppendValues(spreadsheetID, rng string, rows [][]interface{}) (string, error) {
+	l.appends.Add(1)
+	updated, err := l.SpreadsheetBackend.AppendValues(spreadsheetID, rng, rows)
+	if err == nil && l.lose.Load() {
+		return "", errTest
+	}
+	return updated, err
+}
+
+// newAppendControl создаёт SheetsControl с журналом; повтор из журнала тесты вызывают сами
+func newAppendControl(t *testing.T, b backend.SpreadsheetBackend, opts Options) (*SheetsControl, *outbox.Outbox) {
+	t.Helper()
+	journal, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.log"))
+	if err != nil {
+		t.Fatalf("outbox.Open: %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	sc := newTestControl(t, b, opts)
+	sc.outbox = journal
+	return sc, journal
+}
+
+// rawRows возвращает число строк RAW валюты
+func rawRows(t *testing.T, sc *SheetsControl, fiat string) int {
+	t.Helper()
+	raw, err := sc.ReadRaw(fiat)
+	if err != nil {
+		t.Fatalf("ReadRaw: %v", err)
+	}
+	return len(raw.Data)
+}
+
+// Повтор дозаписи, ответ на которую потерян, не дописывает строки второй раз
+func TestReplayedAppendWritesRowsOnce(t *testing.T) {
+	b := &lostAppendBackend{SpreadsheetBackend: memoryBackend.New()}
+	sc, journal := newAppendControl(t, b, Options{})
+
+	if _, err := sc.UpsertRaw("USD", testSheetData("USD", "1").RAWData); err != nil {
+		t.Fatalf("UpsertRaw: %v", err)
+	}
+
+	b.lose.Store(true)
+	outcome, err := sc.AppendRaw("USD", models.RAWData{Date: "2025-01-31", Data: [][]string{{"r3", "2"}}})
+	if outcome != OutcomeDeferred || err == nil {
+		t.Fatalf("AppendRaw = %s, %v; want deferred", outcome, err)
+	}
+	if n := b.appends.Load(); n != 1 {
+		t.Fatalf("values.append вызван %d раз, want 1: запись не должна повторяться сразу", n)
+	}
+	b.lose.Store(false)
+
+	sc.replayOutbox()
+	sc.replayOutbox()
+	if n := rawRows(t, sc, "USD"); n != 3 {
+		t.Fatalf("в RAW %d строк, want 3", n)
+	}
+	if n := b.appends.Load(); n != 1 {
+		t.Fatalf("values.append вызван %d раз, want 1", n)
+	}
+	if n := journal.Len(); n != 0 {
+		t.Fatalf("в журнале осталось %d записей", n)
+	}
+
+	// Более поздние строки дописываются после подтверждения ранних
+	if _, err := sc.AppendRaw("USD", models.RAWData{Date: "2025-01-31", Data: [][]string{{"r4", "3"}}}); err != nil {
+		t.Fatalf("AppendRaw: %v", err)
+	}
+	if n := rawRows(t, sc, "USD"); n != 4 {
+		t.Fatalf("в RAW %d строк, want 4", n)
+	}
+}
+
+// Дозапись не переносит строки на RAW_2 и отклоняется, когда лист RAW заполнен до RawMaxRows
+func TestAppendAtRawMaxRows(t *testing.T) {
+	b := memoryBackend.New()
+	sc, journal := newAppendControl(t, b, Options{RawMaxRows: 3})
+
+	if _, err := sc.UpsertRaw("USD", testSheetData("USD", "1").RAWData); err != nil {
+		t.Fatalf("UpsertRaw: %v", err)
+	}
+	if _, err := sc.AppendRaw("USD", models.RAWData{Data: [][]string{{"r3", "1"}}}); err != nil {
+		t.Fatalf("дозапись до RawMaxRows: %v", err)
+	}
+
+	outcome, err := sc.AppendRaw("USD", models.RAWData{Data: [][]string{{"r4", "1"}}})
+	var validationErr *apperrors.ValidationError
+	if outcome != OutcomeFailed || !errors.As(err, &validationErr) {
+		t.Fatalf("дозапись сверх RawMaxRows = %s, %v; want failed с ошибкой проверки", outcome, err)
+	}
+	if n := rawRows(t, sc, "USD"); n != 3 {
+		t.Fatalf("в RAW %d строк, want 3", n)
+	}
+	if n := journal.Len(); n != 0 {
+		t.Fatalf("отклонённые данные остались в журнале: %d записей", n)
+	}
+
+	// Строки уже перенесены на RAW_2: дописанная под RAW строка оказалась бы перед строками RAW_2
+	spilled := models.RAWData{Data: [][]string{{"r1", "2"}, {"r2", "2"}, {"r3", "2"}, {"r4", "2"}}}
+	if _, err := sc.UpsertRaw("USD", spilled); err != nil {
+		t.Fatalf("UpsertRaw: %v", err)
+	}
+	if _, err := sc.AppendRaw("USD", models.RAWData{Data: [][]string{{"r5", "2"}}}); !errors.As(err, &validationErr) {
+		t.Fatalf("дозапись при листе RAW_2 = %v; want ошибку проверки", err)
+	}
+	if n := rawRows(t, sc, "USD"); n != 4 {
+		t.Fatalf("в RAW и RAW_2 %d строк, want 4", n)
+	}
+}
@@ -0,0 +1,53 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/models"
+	"errors"
+	"sync"
+)
+
+// OutcomeFailed - запись не удалась; используется только в результатах пакетной записи
+const OutcomeFailed WriteOutcome = "failed"
+
+// SetSheetDataBulk записывает данные нескольких валют, не больше BulkWorkers одновременно.
+// Каждая валюта записывается как отдельный вызов SetSheetData: ошибка одной не прерывает остальные.
+// Результаты возвращаются в порядке данных
+func (sc *SheetsControl) SetSheetDataBulk(data []models.SheetData) []models.BulkWriteResult {
+	results := make([]models.BulkWriteResult, len(data))
+	slots := make(chan struct{}, sc.bulk)
+	var wg sync.WaitGroup
+	for i := range data {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if data[i].Fiat == "" {
+				results[i] = bulkResult("", "", &apperrors.ValidationError{Field: "fiat", Msg: "не задана валюта"})
+				return
+			}
+			outcome, err := sc.SetSheetData(data[i])
+			results[i] = bulkResult(data[i].Fiat, outcome, err)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func bulkResult(fiat string, outcome WriteOutcome, err error) models.BulkWriteResult {
+	result := models.BulkWriteResult{Fiat: fiat, Status: string(outcome)}
+	if err == nil {
+		return result
+	}
+	if outcome != OutcomeDeferred {
+		result.Status = string(OutcomeFailed)
+	}
+	result.Error = err.Error()
+	var stepErr *apperrors.StepError
+	if errors.As(err, &stepErr) {
+		result.FailedStep = stepErr.Step
+		result.RolledBack = stepErr.RolledBack
+	}
+	return result
+}
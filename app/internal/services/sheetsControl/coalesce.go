@@ -0,0 +1,76 @@
+package sheetsControl
+
+import "sync"
+
+// WriteOutcome - результат обработки данных валюты
+type WriteOutcome string
+
+const (
+	OutcomeWritten    WriteOutcome = "written"    // данные записаны в таблицу
+	OutcomeSuperseded WriteOutcome = "superseded" // данные заменены более новыми до начала записи
+)
+
+// fiatSlot - очередь записи одной валюты: выполняется не больше одной записи,
+// и ждёт не больше одной - самой свежей
+type fiatSlot struct {
+	busy    bool
+	pending *pendingWrite
+}
+
+type pendingWrite struct {
+	ready chan bool // true - можно писать, false - данные устарели
+}
+
+// coalescer сериализует записи по ключу (models.WriteScope.Key), оставляя в ожидании только последние данные
+type coalescer struct {
+	slots map[string]*fiatSlot
+	mu    sync.Mutex
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{slots: make(map[string]*fiatSlot)}
+}
+
+// do выполняет write, когда подойдёт очередь ключа. Если за время ожидания пришли более
+// новые данные, write не вызывается и возвращается OutcomeSuperseded
+func (c *coalescer) do(key string, write func() error) (WriteOutcome, error) {
+	c.mu.Lock()
+	slot, ok := c.slots[key]
+	if !ok {
+		slot = &fiatSlot{}
+		c.slots[key] = slot
+	}
+
+	if slot.busy {
+		if slot.pending != nil {
+			slot.pending.ready <- false
+		}
+		pending := &pendingWrite{ready: make(chan bool, 1)}
+		slot.pending = pending
+		c.mu.Unlock()
+
+		if !<-pending.ready {
+			return OutcomeSuperseded, nil
+		}
+	} else {
+		slot.busy = true
+		c.mu.Unlock()
+	}
+
+	defer c.release(key, slot)
+	return OutcomeWritten, write()
+}
+
+// release передаёт очередь ожидающей записи или освобождает ключ
+func (c *coalescer) release(key string, slot *fiatSlot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slot.pending != nil {
+		slot.pending.ready <- true
+		slot.pending = nil
+		return
+	}
+	slot.busy = false
+	delete(c.slots, key)
+}
@@ -0,0 +1,144 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"GoogleSheetW/internal/models"
+	"google.golang.org/api/sheets/v4"
+	"time"
+)
+
+// blankValues возвращает пустые значения для частей прошлых областей записи old, которые не покрывает
+// новая запись written. Так очистка выполняется тем же values.batchUpdate, что и запись
+func blankValues(old, written []string) []*sheets.ValueRange {
+	var cuts []a1Notation.Range
+	for _, rng := range written {
+		if r, err := a1Notation.Parse(rng); err == nil {
+			cuts = append(cuts, r)
+		}
+	}
+
+	var result []*sheets.ValueRange
+	for _, rng := range old {
+		r, err := a1Notation.Parse(rng)
+		if err != nil || r.EndRow == a1Notation.Unbounded || r.EndCol == a1Notation.Unbounded {
+			continue
+		}
+		parts := []a1Notation.Range{r}
+		for _, cut := range cuts {
+			var rest []a1Notation.Range
+			for _, part := range parts {
+				rest = append(rest, subtractRange(part, cut)...)
+			}
+			parts = rest
+		}
+		for _, part := range parts {
+			rows := make([][]interface{}, part.EndRow-part.StartRow)
+			for i := range rows {
+				rows[i] = make([]interface{}, part.EndCol-part.StartCol)
+				for j := range rows[i] {
+					rows[i][j] = ""
+				}
+			}
+			result = append(result, &sheets.ValueRange{Range: part.String(), Values: rows})
+		}
+	}
+	return result
+}
+
+// subtractRange возвращает части диапазона r, не покрытые диапазоном cut
+func subtractRange(r, cut a1Notation.Range) []a1Notation.Range {
+	if cut.Sheet != r.Sheet || cut.EndRow == a1Notation.Unbounded || cut.EndCol == a1Notation.Unbounded ||
+		cut.StartRow >= r.EndRow || cut.EndRow <= r.StartRow || cut.StartCol >= r.EndCol || cut.EndCol <= r.StartCol {
+		return []a1Notation.Range{r}
+	}
+	var parts []a1Notation.Range
+	if cut.StartRow > r.StartRow {
+		top := r
+		top.EndRow = cut.StartRow
+		parts = append(parts, top)
+	}
+	if cut.EndRow < r.EndRow {
+		bottom := r
+		bottom.StartRow = cut.EndRow
+		parts = append(parts, bottom)
+	}
+	middle := r
+	middle.StartRow = max(r.StartRow, cut.StartRow)
+	middle.EndRow = min(r.EndRow, cut.EndRow)
+	if cut.StartCol > r.StartCol {
+		left := middle
+		left.EndCol = cut.StartCol
+		parts = append(parts, left)
+	}
+	if cut.EndCol < r.EndCol {
+		right := middle
+		right.StartCol = cut.EndCol
+		parts = append(parts, right)
+	}
+	return parts
+}
+
+// clearRequests возвращает запросы очистки областей ranges листа sheetID. Они нужны, когда размер прошлой
+// записи неизвестен (потерянный кэш): области из раскладки могут быть не ограничены снизу
+func clearRequests(sheetID int64, ranges []string) []*sheets.Request {
+	var requests []*sheets.Request
+	for _, rng := range ranges {
+		r, err := a1Notation.Parse(rng)
+		if err != nil {
+			continue
+		}
+		grid := &sheets.GridRange{
+			SheetId:          sheetID,
+			StartRowIndex:    int64(r.StartRow),
+			StartColumnIndex: int64(r.StartCol),
+		}
+		if r.EndRow != a1Notation.Unbounded {
+			grid.EndRowIndex = int64(r.EndRow)
+		}
+		if r.EndCol != a1Notation.Unbounded {
+			grid.EndColumnIndex = int64(r.EndCol)
+		}
+		requests = append(requests, &sheets.Request{UpdateCells: &sheets.UpdateCellsRequest{
+			Range:  grid,
+			Fields: "userEnteredValue",
+		}})
+	}
+	return requests
+}
+
+// hasClearRequests сообщает, очищают ли запросы ячейки существующих листов
+func hasClearRequests(requests []*sheets.Request) bool {
+	for _, request := range requests {
+		if request.UpdateCells != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSheetStates запоминает области, занятые записью, чтобы очистить ровно их перед следующей записью,
+// а также ID и размеры сеток листов. Ошибка кэша не отменяет уже выполненную запись
+func (sc *SheetsControl) saveSheetStates(fiat string, extents map[string][]string, grids map[string]gridSize, sheetIDs map[string]int64) {
+	now := time.Now().UTC()
+	titles := make(map[string]bool, len(extents)+len(grids))
+	for title := range extents {
+		titles[title] = true
+	}
+	for title := range grids {
+		titles[title] = true
+	}
+	for sheetName := range titles {
+		state := models.SheetState{
+			Extents:   extents[sheetName],
+			GridRows:  grids[sheetName].rows,
+			GridCols:  grids[sheetName].cols,
+			UpdatedAt: now,
+		}
+		if id, ok := sheetIDs[sheetName]; ok {
+			state.SheetID = &id
+		}
+		if err := sc.cache.SetSheetState(fiat, sheetName, state); err != nil {
+			sc.log.Warnw("Ошибка сохранения размера записи в кэш", "fiat", fiat, "sheetName", sheetName, "error", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend/googleBackend"
+	"GoogleSheetW/internal/services/fakeGoogle"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// newFakeGoogleControl создаёт SheetsControl, который обращается к fakeGoogle через googleBackend,
+// так что запросы проходят через клиентов Google API, повторы и разбор ошибок
+func newFakeGoogleControl(t *testing.T) (*SheetsControl, *fakeGoogle.Server) {
+	t.Helper()
+	srv := fakeGoogle.New()
+	t.Cleanup(srv.Close)
+	b, err := googleBackend.New(context.Background(), googleBackend.Options{Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("googleBackend.New: %v", err)
+	}
+	return newTestControl(t, b, Options{}), srv
+}
+
+// Запись через Google API создаёт таблицу и листы, данные читаются обратно
+func TestFakeGoogleWrite(t *testing.T) {
+	sc, srv := newFakeGoogleControl(t)
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	spreadsheets, _ := srv.Store.ListSpreadsheets()
+	if len(spreadsheets) != 1 {
+		t.Fatalf("таблиц %d, want 1", len(spreadsheets))
+	}
+	soup, err := sc.ReadSoup("USD", "soup")
+	if err != nil {
+		t.Fatalf("ReadSoup: %v", err)
+	}
+	if got := soup.Data[1][1]; got != "1" {
+		t.Fatalf("в листе %q, want 1", got)
+	}
+	raw, err := sc.ReadRaw("USD")
+	if err != nil {
+		t.Fatalf("ReadRaw: %v", err)
+	}
+	if len(raw.Data) != 2 {
+		t.Fatalf("в RAW %d строк, want 2", len(raw.Data))
+	}
+}
+
+// Таблица, удалённая вне сервиса, создаётся заново: 404 Google распознаётся и запись повторяется
+func TestFakeGoogleRepairsDeletedSpreadsheet(t *testing.T) {
+	sc, srv := newFakeGoogleControl(t)
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	deletedID, _ := sc.cache.GetIDbyFiat("USD")
+	if err := srv.Store.DeleteSpreadsheet(deletedID); err != nil {
+		t.Fatalf("DeleteSpreadsheet: %v", err)
+	}
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "2")); err != nil {
+		t.Fatalf("запись после удаления таблицы: %v", err)
+	}
+	id, err := sc.cache.GetIDbyFiat("USD")
+	if err != nil || id == deletedID {
+		t.Fatalf("в кэше %q, %v; want новую таблицу", id, err)
+	}
+	soup, err := sc.ReadSoup("USD", "soup")
+	if err != nil {
+		t.Fatalf("ReadSoup: %v", err)
+	}
+	if got := soup.Data[1][1]; got != "2" {
+		t.Fatalf("в листе %q, want 2", got)
+	}
+}
+
+// Ошибка Google при записи значений отменяет листы, созданные этой записью, а повтор записывает данные
+func TestFakeGoogleFailedWriteRollsBackSheets(t *testing.T) {
+	sc, srv := newFakeGoogleControl(t)
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	data := testSheetData("USD", "2")
+	data.SoupList[0].Name = "soup2"
+	srv.InjectFault(fakeGoogle.Fault{Method: http.MethodPost, Path: "values:batchUpdate", Status: http.StatusBadRequest, Times: 1})
+
+	_, err := sc.SetSheetData(data)
+	var stepErr *apperrors.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepWriteValues || !stepErr.RolledBack {
+		t.Fatalf("err = %v, want шаг %s с отменой", err, StepWriteValues)
+	}
+	id, _ := sc.cache.GetIDbyFiat("USD")
+	sheetList, err := srv.Store.ListSheets(id)
+	if err != nil {
+		t.Fatalf("ListSheets: %v", err)
+	}
+	for _, sheet := range sheetList {
+		if sheet.Title == "soup2" {
+			t.Fatal("лист soup2 не удалён после ошибки")
+		}
+	}
+
+	if _, err := sc.SetSheetData(data); err != nil {
+		t.Fatalf("повторная запись: %v", err)
+	}
+	soup, err := sc.ReadSoup("USD", "soup2")
+	if err != nil {
+		t.Fatalf("ReadSoup: %v", err)
+	}
+	if got := soup.Data[1][1]; got != "2" {
+		t.Fatalf("в листе %q, want 2", got)
+	}
+}
@@ -0,0 +1,55 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRegion - регион валюты, которой нет в настройках регионов
+const defaultRegion = "other"
+
+// folderPath раскрывает шаблон подпапок для валюты, например "{region}/{year}" -> ["europe", "2025"].
+// Поддерживаются {year}, {month}, {fiat} и {region}; пустые сегменты пропускаются
+func (sc *SheetsControl) folderPath(fiat string, now time.Time) []string {
+	region, ok := sc.fiatRegions[fiat]
+	if !ok {
+		region = defaultRegion
+	}
+	replacer := strings.NewReplacer(
+		"{year}", strconv.Itoa(now.Year()),
+		"{month}", fmt.Sprintf("%02d", int(now.Month())),
+		"{fiat}", fiat,
+		"{region}", region,
+	)
+
+	var path []string
+	for _, segment := range strings.Split(sc.folderTemplate, "/") {
+		segment = strings.TrimSpace(replacer.Replace(segment))
+		if segment != "" {
+			path = append(path, segment)
+		}
+	}
+	return path
+}
+
+// placeSpreadsheet переносит новую таблицу в подпапку по шаблону, создавая недостающие папки
+func (sc *SheetsControl) placeSpreadsheet(b backend.SpreadsheetBackend, fiat, spreadsheetID string) error {
+	path := sc.folderPath(fiat, time.Now().UTC())
+	if len(path) == 0 {
+		return nil
+	}
+	folderID, err := b.EnsureFolder(path)
+	if err != nil {
+		sc.log.Errorw("Ошибка создания папки для таблицы", "fiat", fiat, "path", strings.Join(path, "/"), "error", err)
+		return err
+	}
+	if err := b.MoveToFolder(spreadsheetID, folderID); err != nil {
+		sc.log.Errorw("Ошибка переноса таблицы в папку", "fiat", fiat, "spreadsheetID", spreadsheetID, "folderID", folderID, "error", err)
+		return err
+	}
+	sc.log.Infow("Таблица перенесена в папку", "fiat", fiat, "path", strings.Join(path, "/"), "folderID", folderID)
+	return nil
+}
@@ -0,0 +1,65 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/a1Notation"
+	"google.golang.org/api/sheets/v4"
+)
+
+// Размер сетки нового листа в Google Sheets
+const (
+	defaultGridRows = 1000
+	defaultGridCols = 26
+)
+
+// gridSize - размер сетки листа
+type gridSize struct {
+	rows, cols int64
+}
+
+// fits сообщает, помещается ли в сетку область размера need
+func (g gridSize) fits(need gridSize) bool {
+	return g.rows >= need.rows && g.cols >= need.cols
+}
+
+// grow возвращает сетку, увеличенную до размера need
+func (g gridSize) grow(need gridSize) gridSize {
+	return gridSize{rows: max(g.rows, need.rows), cols: max(g.cols, need.cols)}
+}
+
+// gridNeed возвращает размер сетки, в которую помещаются записи values
+func gridNeed(values []*sheets.ValueRange) gridSize {
+	var need gridSize
+	for _, vr := range values {
+		r, err := a1Notation.Parse(vr.Range)
+		if err != nil {
+			continue
+		}
+		width := 0
+		for _, row := range vr.Values {
+			width = max(width, len(row))
+		}
+		need.rows = max(need.rows, int64(r.StartRow+len(vr.Values)))
+		need.cols = max(need.cols, int64(r.StartCol+width))
+	}
+	return need
+}
+
+// appendDimensionRequests возвращает запросы, увеличивающие сетку листа grid до размера need
+func appendDimensionRequests(sheetID int64, grid, need gridSize) []*sheets.Request {
+	var requests []*sheets.Request
+	if need.rows > grid.rows {
+		requests = append(requests, &sheets.Request{AppendDimension: &sheets.AppendDimensionRequest{
+			SheetId:   sheetID,
+			Dimension: "ROWS",
+			Length:    need.rows - grid.rows,
+		}})
+	}
+	if need.cols > grid.cols {
+		requests = append(requests, &sheets.Request{AppendDimension: &sheets.AppendDimensionRequest{
+			SheetId:   sheetID,
+			Dimension: "COLUMNS",
+			Length:    need.cols - grid.cols,
+		}})
+	}
+	return requests
+}
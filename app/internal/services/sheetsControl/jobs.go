@@ -0,0 +1,152 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend/recordingBackend"
+	"GoogleSheetW/internal/models"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// jobTask - задача записи и её журнал запросов к хранилищу
+type jobTask struct {
+	job      models.Job
+	data     models.SheetData
+	seq      uint64 // номер данных в журнале исходящих данных
+	recorder *recordingBackend.RecordingBackend
+}
+
+// jobQueue хранит фоновые задачи и очередь на выполнение
+type jobQueue struct {
+	tasks     map[string]*jobTask
+	queue     chan *jobTask
+	retention time.Duration
+	mu        sync.RWMutex
+}
+
+func newJobQueue(size int, retention time.Duration) *jobQueue {
+	return &jobQueue{
+		tasks:     make(map[string]*jobTask),
+		queue:     make(chan *jobTask, max(size, 1)),
+		retention: retention,
+	}
+}
+
+// startJobWorkers запускает пул обработчиков фоновых задач
+func (sc *SheetsControl) startJobWorkers(workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			for {
+				select {
+				case <-sc.ctx.Done():
+					return
+				case task := <-sc.jobs.queue:
+					sc.runJob(task)
+				}
+			}
+		}()
+	}
+}
+
+// SubmitSheetData ставит запись данных в очередь и сразу возвращает созданную задачу
+func (sc *SheetsControl) SubmitSheetData(data models.SheetData) (models.Job, error) {
+	// Задачи ставятся в очередь только здесь и под блокировкой, поэтому место, найденное до записи в журнал,
+	// не займёт другая задача: данные отклонённой задачи не попадают в журнал и не отправляются при повторе
+	sc.jobs.mu.Lock()
+	defer sc.jobs.mu.Unlock()
+
+	sc.jobs.removeExpired()
+	if len(sc.jobs.queue) == cap(sc.jobs.queue) {
+		return models.Job{}, apperrors.ErrJobQueueFull
+	}
+	// Данные сохраняются на диск до постановки в очередь, чтобы пережить перезапуск
+	seq, err := sc.saveToOutbox(data, models.ScopeAll)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	task := &jobTask{
+		job: models.Job{
+			ID:        uuid.NewString(),
+			Fiat:      data.Fiat,
+			Status:    models.JobQueued,
+			CreatedAt: time.Now(),
+		},
+		data:     data,
+		seq:      seq,
+		recorder: recordingBackend.New(sc.backend),
+	}
+	sc.jobs.queue <- task
+	sc.jobs.tasks[task.job.ID] = task
+
+	sc.log.Infow("Задача записи поставлена в очередь", "job_id", task.job.ID, "fiat", data.Fiat)
+	return sc.jobs.snapshotLocked(task), nil
+}
+
+// GetJob возвращает состояние задачи по ID
+func (sc *SheetsControl) GetJob(id string) (models.Job, error) {
+	sc.jobs.mu.RLock()
+	defer sc.jobs.mu.RUnlock()
+
+	task, ok := sc.jobs.tasks[id]
+	if !ok {
+		return models.Job{}, apperrors.ErrJobNotFound
+	}
+	return sc.jobs.snapshotLocked(task), nil
+}
+
+func (sc *SheetsControl) runJob(task *jobTask) {
+	started := time.Now()
+	sc.jobs.mu.Lock()
+	task.job.Status = models.JobRunning
+	task.job.StartedAt = &started
+	sc.jobs.mu.Unlock()
+
+	outcome, err := sc.deliver(task.recorder, task.data, models.ScopeAll, task.seq)
+
+	finished := time.Now()
+	sc.jobs.mu.Lock()
+	defer sc.jobs.mu.Unlock()
+
+	task.job.FinishedAt = &finished
+	var stepErr *apperrors.StepError
+	if errors.As(err, &stepErr) {
+		task.job.FailedStep = stepErr.Step
+		task.job.RolledBack = stepErr.RolledBack
+	}
+	switch {
+	case outcome == OutcomeDeferred:
+		task.job.Status = models.JobDeferred
+		task.job.Error = err.Error()
+	case err != nil:
+		task.job.Status = models.JobFailed
+		task.job.Error = err.Error()
+		sc.log.Errorw("Ошибка выполнения задачи записи", "job_id", task.job.ID, "fiat", task.data.Fiat, "error", err)
+	case outcome == OutcomeSuperseded:
+		task.job.Status = models.JobSuperseded
+	default:
+		task.job.Status = models.JobSucceeded
+	}
+	// Данные больше не нужны, в памяти остаётся только отчёт
+	task.data = models.SheetData{}
+}
+
+func (q *jobQueue) snapshotLocked(task *jobTask) models.Job {
+	job := task.job
+	job.Calls = task.recorder.Calls()
+	return job
+}
+
+// removeExpired удаляет завершённые задачи старше срока хранения; вызывается под блокировкой
+func (q *jobQueue) removeExpired() {
+	if q.retention <= 0 {
+		return
+	}
+	for id, task := range q.tasks {
+		if task.job.FinishedAt != nil && time.Since(*task.job.FinishedAt) > q.retention {
+			delete(q.tasks, id)
+		}
+	}
+}
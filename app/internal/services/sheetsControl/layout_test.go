@@ -0,0 +1,119 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/layout"
+	"GoogleSheetW/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testLayout - раскладка, отличная от встроенной: другие ячейки, порядок блоков и заголовки таблицы RAW
+const testLayout = `
+soup:
+  init:
+    - anchor: A1
+      rows:
+        - [{label: "Суп"}]
+  blocks:
+    - anchor: C2
+      rows:
+        - [{label: "Цена:"}, {field: fixed_price}, {label: "Лучшая:"}, {field: best_price}]
+        - [{label: "Ссылка:"}, {field: best_price_link}]
+        - [{label: "Дата:"}, {field: date}]
+        - [{label: "Объём:"}, {field: money_supply}, {label: "Выборка:"}, {field: average_size}]
+    - anchor: B8
+      for_each: info_filters
+      rows:
+        - [{field: exchange}, {field: banks_name}, {field: month_order}]
+        - [{field: month_finish_rate}, {field: max_low_single_trans_amount}, {field: min_high_single_trans_amount}, {field: average_size}]
+      table:
+        source: data
+        columns: [Биржа, Цена, Ссылка]
+raw:
+  blocks:
+    - anchor: D1
+      rows:
+        - [{label: "Записано"}, {field: date}]
+    - anchor: B3
+      table:
+        source: raw_data
+        columns: [Время, Биржа, Цена]
+raw_filter:
+  init:
+    - anchor: A1
+      rows:
+        - [{label: "=QUERY({{raw!$B4:D}},\"select Col1 where Col1 is not null\")"}]
+`
+
+// Данные, записанные по раскладке из файла, читаются обратно по той же раскладке без потерь
+func TestLayoutFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layout.yaml")
+	if err := os.WriteFile(path, []byte(testLayout), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sheetLayout, err := layout.Load(path)
+	if err != nil {
+		t.Fatalf("layout.Load: %v", err)
+	}
+	b := memoryBackend.New()
+	sc := newTestControl(t, b, Options{Layout: sheetLayout})
+
+	soup := models.Soup{
+		Name:          "soup",
+		FixedPrice:    91.5,
+		BestPrice:     90.25,
+		BestPriceLink: "https://p2p.example/ad/1",
+		Date:          "2025-01-30 12:00:00",
+		MoneySupply:   15000,
+		AverageSize:   20,
+		InfoFilters: []models.InfoFilterSheet{
+			{Exchange: "Binance", BanksName: []string{"Tinkoff", "Sber"}, MonthOrder: 120, MonthFinishRate: 0.98,
+				MaxLowSingleTransAmount: 1000, MinHighSingleTransAmount: 50000, AverageSize: 10},
+			{Exchange: "Bybit", BanksName: []string{"Raif"}, MonthOrder: 40, MonthFinishRate: 0.9,
+				MaxLowSingleTransAmount: 500, MinHighSingleTransAmount: 20000, AverageSize: 5},
+		},
+		Data: [][]string{{"Binance", "91.5", "https://p2p.example/ad/1"}, {"Bybit", "92", "https://p2p.example/ad/2"}},
+	}
+	raw := models.RAWData{
+		Date: "2025-01-30 12:00:00",
+		Data: [][]string{{"2025-01-30 11:59:00", "Binance", "91.5"}, {"2025-01-30 12:00:00", "Bybit", "92"}},
+	}
+	if _, err := sc.SetSheetData(models.SheetData{Fiat: "USD", SoupList: []models.Soup{soup}, RAWData: raw}); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+
+	// Значения лежат там, где их размещает раскладка из файла, а не встроенная
+	id, _ := sc.cache.GetIDbyFiat("USD")
+	rows, err := b.ReadValues(id, "soup")
+	if err != nil {
+		t.Fatalf("ReadValues: %v", err)
+	}
+	if len(rows) < 2 || len(rows[1]) < 4 || rows[1][2] != "Цена:" || rows[1][3] != "91.5" {
+		t.Fatalf("строка 2 листа soup = %v, want подпись и цену в C2:D2", rows[1])
+	}
+	rows, err = b.ReadValues(id, "RAW")
+	if err != nil {
+		t.Fatalf("ReadValues: %v", err)
+	}
+	if len(rows) < 3 || len(rows[2]) < 2 || rows[2][1] != "Время" {
+		t.Fatalf("строка 3 листа RAW = %v, want заголовки таблицы с B3", rows[2])
+	}
+
+	gotSoup, err := sc.ReadSoup("USD", "soup")
+	if err != nil {
+		t.Fatalf("ReadSoup: %v", err)
+	}
+	if !reflect.DeepEqual(gotSoup, soup) {
+		t.Fatalf("ReadSoup = %+v\nwant %+v", gotSoup, soup)
+	}
+	gotRaw, err := sc.ReadRaw("USD")
+	if err != nil {
+		t.Fatalf("ReadRaw: %v", err)
+	}
+	if !reflect.DeepEqual(gotRaw, raw) {
+		t.Fatalf("ReadRaw = %+v\nwant %+v", gotRaw, raw)
+	}
+}
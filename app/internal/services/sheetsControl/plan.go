@@ -0,0 +1,263 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/layout"
+	"GoogleSheetW/internal/models"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"math/rand/v2"
+)
+
+// writeTarget - лист, который перезаписывается при обновлении: суп или лист данных RAW
+type writeTarget struct {
+	name    string
+	layout  *layout.Sheet
+	raw     bool
+	values  []*sheets.ValueRange
+	extents []string
+	need    gridSize
+}
+
+// writePlan - все изменения одной записи: структурные изменения (новые листы, фильтр,
+// увеличение сетки, очистка при неизвестном размере прошлой записи) выполняются одним
+// spreadsheets.batchUpdate, а очистка прежних данных вместе с записью - одним values.batchUpdate
+type writePlan struct {
+	requests  []*sheets.Request
+	values    []*sheets.ValueRange
+	newSheets []string // листы, которые добавляются в кэш после записи
+	extents   map[string][]string
+	grids     map[string]gridSize
+	sheetIDs  map[string]int64
+}
+
+// planWrite составляет план записи. Из хранилища только читается список листов, и только если без него
+// не обойтись: есть новые листы, размер прошлой записи неизвестен или запись не помещается в сетку
+func (sc *SheetsControl) planWrite(b backend.SpreadsheetBackend, spreadsheetID string, data models.SheetData, scope models.WriteScope) (*writePlan, error) {
+	targets, err := sc.writeTargets(data, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &writePlan{
+		extents:  make(map[string][]string, len(targets)),
+		grids:    make(map[string]gridSize, len(targets)),
+		sheetIDs: make(map[string]int64, len(targets)),
+	}
+	cached := make(map[string]bool, len(targets))
+	states := make(map[string]models.SheetState, len(targets))
+	listNeeded := false
+	for _, t := range targets {
+		ok, err := sc.cache.IsSupInCashed(data.Fiat, t.name)
+		if err != nil {
+			sc.log.Errorw(fmt.Sprintf("ошибка чтения кэша %s %s", data.Fiat, t.name), err)
+			return nil, err
+		}
+		cached[t.name] = ok
+		state, known, err := sc.cache.GetSheetState(data.Fiat, t.name)
+		if err != nil {
+			sc.log.Warnw("Ошибка чтения размера прошлой записи из кэша", "fiat", data.Fiat, "sheetName", t.name, "error", err)
+		}
+		if err == nil && known && state.Written() {
+			states[t.name] = state
+		}
+		if !ok || err != nil || !known || !state.Written() || !(gridSize{rows: state.GridRows, cols: state.GridCols}).fits(t.need) {
+			listNeeded = true
+		}
+		plan.extents[t.name] = t.extents
+	}
+
+	filterNeed := gridSize{}
+	if scope != models.ScopeSoup {
+		filterNeed = sc.rawFilterNeed(len(data.RAWData.Data))
+	}
+	if filterNeed.rows > 0 {
+		// Результат формулы RAW_filter растёт вместе с листами данных, и его сетка тоже должна расти
+		state, known, err := sc.cache.GetSheetState(data.Fiat, "RAW_filter")
+		if err != nil || !known || !(gridSize{rows: state.GridRows, cols: state.GridCols}).fits(filterNeed) {
+			listNeeded = true
+		}
+	}
+
+	if !listNeeded {
+		for _, t := range targets {
+			state := states[t.name]
+			plan.values = append(plan.values, blankValues(state.Extents, t.extents)...)
+			plan.values = append(plan.values, t.values...)
+			plan.grids[t.name] = gridSize{rows: state.GridRows, cols: state.GridCols}
+			if state.SheetID != nil {
+				plan.sheetIDs[t.name] = *state.SheetID
+			}
+		}
+		return plan, nil
+	}
+
+	sheetList, err := b.ListSheets(spreadsheetID)
+	if err != nil {
+		sc.log.Errorw("Ошибка получения листов таблицы", "fiat", data.Fiat, "spreadsheetID", spreadsheetID, "error", err)
+		return nil, err
+	}
+	listed := make(map[string]backend.SheetInfo, len(sheetList))
+	usedIDs := make(map[int64]bool, len(sheetList))
+	for _, sheet := range sheetList {
+		listed[sheet.Title] = sheet
+		usedIDs[sheet.SheetID] = true
+	}
+
+	var rawNames []string
+	rewriteRawFilter := false
+	rawAdded := false
+	for _, t := range targets {
+		if t.raw {
+			rawNames = append(rawNames, t.name)
+		}
+		sheet, exists := listed[t.name]
+		if !exists {
+			requests, values, grid := sc.addSheetRequests(t, listed, usedIDs)
+			plan.requests = append(plan.requests, requests...)
+			plan.values = append(plan.values, values...)
+			plan.values = append(plan.values, t.values...)
+			plan.grids[t.name] = grid
+			plan.sheetIDs[t.name] = createdSheetIDs(requests)[0]
+			plan.newSheets = append(plan.newSheets, t.name)
+			rewriteRawFilter = rewriteRawFilter || t.raw
+			rawAdded = rawAdded || t.name == "RAW"
+			continue
+		}
+
+		if !cached[t.name] {
+			// Лист есть в таблице, но не в кэше: пришёл из шаблона или создан записью, которая не завершилась
+			plan.newSheets = append(plan.newSheets, t.name)
+			if sc.templateSpreadsheetID == "" {
+				plan.values = append(plan.values, t.layout.InitValues(t.name)...)
+				rewriteRawFilter = rewriteRawFilter || t.raw
+			}
+		}
+		grid := gridSize{rows: sheet.RowCount, cols: sheet.ColumnCount}
+		plan.requests = append(plan.requests, appendDimensionRequests(sheet.SheetID, grid, t.need)...)
+		plan.grids[t.name] = grid.grow(t.need)
+		plan.sheetIDs[t.name] = sheet.SheetID
+		if state, known := states[t.name]; known {
+			plan.values = append(plan.values, blankValues(state.Extents, t.extents)...)
+		} else {
+			plan.requests = append(plan.requests, clearRequests(sheet.SheetID, t.layout.ClearRanges(t.name))...)
+		}
+		plan.values = append(plan.values, t.values...)
+	}
+	if filter, exists := listed["RAW_filter"]; exists && filterNeed.rows > 0 {
+		grid := gridSize{rows: filter.RowCount, cols: filter.ColumnCount}
+		plan.requests = append(plan.requests, appendDimensionRequests(filter.SheetID, grid, filterNeed)...)
+		plan.grids["RAW_filter"] = grid.grow(filterNeed)
+		plan.sheetIDs["RAW_filter"] = filter.SheetID
+	} else if !exists && rawAdded {
+		// RAW_filter создаётся вместе с листом RAW
+		filterID := newSheetID(usedIDs)
+		plan.requests = append(plan.requests, sc.rawFilterRequests(filterID, filterNeed)...)
+		plan.grids["RAW_filter"] = gridSize{rows: defaultGridRows, cols: defaultGridCols}.grow(filterNeed)
+		plan.sheetIDs["RAW_filter"] = filterID
+	}
+	if rewriteRawFilter {
+		// Формула RAW_filter переписывается, чтобы охватить все листы данных
+		plan.values = append(plan.values, sc.layout.RawFilterValues(rawNames)...)
+	}
+	return plan, nil
+}
+
+// writeTargets собирает записи листов супов и листов данных RAW, входящих в scope
+func (sc *SheetsControl) writeTargets(data models.SheetData, scope models.WriteScope) ([]writeTarget, error) {
+	var chunks []models.RAWData
+	if scope != models.ScopeSoup {
+		var err error
+		chunks, err = sc.rawChunks(data.Fiat, data.RAWData)
+		if err != nil {
+			sc.log.Errorw(fmt.Sprintf("ошибка чтения кэша %s RawData", data.Fiat), err)
+			return nil, err
+		}
+	}
+
+	targets := make([]writeTarget, 0, len(data.SoupList)+len(chunks))
+	if scope != models.ScopeRaw {
+		for _, soup := range data.SoupList {
+			targets = append(targets, newWriteTarget(soup.Name, &sc.layout.Soup, false, soup))
+		}
+	}
+	for i, chunk := range chunks {
+		targets = append(targets, newWriteTarget(rawSheetName(i), &sc.layout.Raw, true, chunk))
+	}
+	return targets, nil
+}
+
+func newWriteTarget(name string, sheetLayout *layout.Sheet, raw bool, data any) writeTarget {
+	values, extents := sheetLayout.Values(name, data)
+	return writeTarget{
+		name:    name,
+		layout:  sheetLayout,
+		raw:     raw,
+		values:  values,
+		extents: extents,
+		need:    gridNeed(append(sheetLayout.InitValues(name), values...)),
+	}
+}
+
+// addSheetRequests возвращает запросы создания листа, подписи, которые в него записываются, и размер его сетки.
+// Лист супа копируется из листа-шаблона, если он есть в таблице
+func (sc *SheetsControl) addSheetRequests(t writeTarget, listed map[string]backend.SheetInfo, usedIDs map[int64]bool) ([]*sheets.Request, []*sheets.ValueRange, gridSize) {
+	sheetID := newSheetID(usedIDs)
+
+	if template, ok := listed[sc.templateSheetName]; ok && !t.raw && sc.templateSpreadsheetID != "" {
+		grid := gridSize{rows: template.RowCount, cols: template.ColumnCount}
+		requests := []*sheets.Request{{DuplicateSheet: &sheets.DuplicateSheetRequest{
+			SourceSheetId: template.SheetID,
+			NewSheetId:    sheetID,
+			NewSheetName:  t.name,
+		}}}
+		requests = append(requests, appendDimensionRequests(sheetID, grid, t.need)...)
+		return requests, nil, grid.grow(t.need)
+	}
+
+	grid := gridSize{rows: defaultGridRows, cols: defaultGridCols}.grow(t.need)
+	requests := []*sheets.Request{{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{
+		SheetId: sheetID,
+		Title:   t.name,
+		GridProperties: &sheets.GridProperties{
+			RowCount:    grid.rows,
+			ColumnCount: grid.cols,
+		},
+	}}}}
+	return requests, t.layout.InitValues(t.name), grid
+}
+
+// newSheetID выбирает свободный ID для нового листа: ID задаётся заранее, чтобы ссылаться на лист
+// в следующих запросах того же batchUpdate
+func newSheetID(usedIDs map[int64]bool) int64 {
+	for {
+		id := rand.Int64N(1<<31-1) + 1
+		if !usedIDs[id] {
+			usedIDs[id] = true
+			return id
+		}
+	}
+}
+
+// createdSheetIDs возвращает ID листов, которые создают запросы плана
+func createdSheetIDs(requests []*sheets.Request) []int64 {
+	var ids []int64
+	for _, request := range requests {
+		switch {
+		case request.AddSheet != nil:
+			ids = append(ids, request.AddSheet.Properties.SheetId)
+		case request.DuplicateSheet != nil:
+			ids = append(ids, request.DuplicateSheet.NewSheetId)
+		}
+	}
+	return ids
+}
+
+// deleteSheetRequests возвращает запросы удаления листов
+func deleteSheetRequests(ids []int64) []*sheets.Request {
+	requests := make([]*sheets.Request, len(ids))
+	for i, id := range ids {
+		requests[i] = &sheets.Request{DeleteSheet: &sheets.DeleteSheetRequest{SheetId: id}}
+	}
+	return requests
+}
@@ -0,0 +1,139 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/backend/recordingBackend"
+	"errors"
+	"google.golang.org/api/sheets/v4"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// batchBackend запоминает запросы каждого BatchUpdate. С failNext в следующий пакет дописывается
+// удаление несуществующего листа, и хранилище отклоняет пакет целиком
+type batchBackend struct {
+	backend.SpreadsheetBackend
+	batches  [][]*sheets.Request
+	failNext bool
+	mu       sync.Mutex
+}
+
+func (b *batchBackend) BatchUpdate(spreadsheetID string, requests []*sheets.Request) ([]*sheets.Response, error) {
+	b.mu.Lock()
+	b.batches = append(b.batches, requests)
+	if b.failNext {
+		b.failNext = false
+		requests = append(requests[:len(requests):len(requests)], &sheets.Request{DeleteSheet: &sheets.DeleteSheetRequest{SheetId: -1}})
+	}
+	b.mu.Unlock()
+	return b.SpreadsheetBackend.BatchUpdate(spreadsheetID, requests)
+}
+
+// requestKinds описывает запросы пакета: вид запроса и имя создаваемого листа
+func requestKinds(requests []*sheets.Request) []string {
+	kinds := make([]string, 0, len(requests))
+	for _, r := range requests {
+		switch {
+		case r.AddSheet != nil:
+			kinds = append(kinds, "AddSheet "+r.AddSheet.Properties.Title)
+		case r.DuplicateSheet != nil:
+			kinds = append(kinds, "DuplicateSheet "+r.DuplicateSheet.NewSheetName)
+		case r.SetBasicFilter != nil:
+			kinds = append(kinds, "SetBasicFilter")
+		case r.AppendDimension != nil:
+			kinds = append(kinds, "AppendDimension")
+		case r.UpdateCells != nil:
+			kinds = append(kinds, "UpdateCells")
+		case r.DeleteSheet != nil:
+			kinds = append(kinds, "DeleteSheet")
+		default:
+			kinds = append(kinds, "?")
+		}
+	}
+	return kinds
+}
+
+// writeCalls возвращает методы вызовов записи структуры и значений в порядке вызова
+func writeCalls(rec *recordingBackend.RecordingBackend) []string {
+	var methods []string
+	for _, call := range rec.Calls() {
+		if call.Method == "BatchUpdate" || call.Method == "WriteValues" {
+			methods = append(methods, call.Method)
+		}
+	}
+	return methods
+}
+
+// Запись меняет структуру одним batchUpdate перед записью значений; повтор с известным размером
+// прошлой записи обходится без batchUpdate
+func TestWriteIsOneBatchUpdate(t *testing.T) {
+	batches := &batchBackend{SpreadsheetBackend: memoryBackend.New()}
+	rec := recordingBackend.New(batches)
+	sc := newTestControl(t, rec, Options{})
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	if got, want := writeCalls(rec), []string{"BatchUpdate", "WriteValues"}; !slices.Equal(got, want) {
+		t.Fatalf("вызовы %v, want %v", got, want)
+	}
+	want := []string{"AddSheet soup", "AddSheet RAW", "AddSheet RAW_filter", "SetBasicFilter"}
+	if got := requestKinds(batches.batches[0]); !slices.Equal(got, want) {
+		t.Fatalf("запросы пакета %v, want %v", got, want)
+	}
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "2")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	if got, want := writeCalls(rec), []string{"BatchUpdate", "WriteValues", "WriteValues"}; !slices.Equal(got, want) {
+		t.Fatalf("вызовы после повторной записи %v, want %v", got, want)
+	}
+}
+
+// Отклонённый batchUpdate не меняет листы таблицы и размер их сетки, и значения после него не записываются
+func TestFailedBatchLeavesGridUntouched(t *testing.T) {
+	batches := &batchBackend{SpreadsheetBackend: memoryBackend.New()}
+	rec := recordingBackend.New(batches)
+	sc := newTestControl(t, rec, Options{})
+
+	if _, err := sc.SetSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SetSheetData: %v", err)
+	}
+	id, _ := sc.cache.GetIDbyFiat("USD")
+	before, err := batches.ListSheets(id)
+	if err != nil {
+		t.Fatalf("ListSheets: %v", err)
+	}
+
+	// Лист soup расширяется, лист soup2 создаётся: ни то, ни другое не должно остаться после ошибки
+	data := testSheetData("USD", "2")
+	data.SoupList[0].Data = append(data.SoupList[0].Data, make([]string, defaultGridCols+4))
+	data.SoupList = append(data.SoupList, testSheetData("USD", "2").SoupList[0])
+	data.SoupList[1].Name = "soup2"
+	batches.failNext = true
+	_, err = sc.SetSheetData(data)
+	var stepErr *apperrors.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepUpdateStructure || !stepErr.RolledBack {
+		t.Fatalf("err = %v, want шаг %s с отменой", err, StepUpdateStructure)
+	}
+	if got, want := requestKinds(batches.batches[len(batches.batches)-1]), []string{"AppendDimension", "AddSheet soup2"}; !slices.Equal(got, want) {
+		t.Fatalf("запросы пакета %v, want %v", got, want)
+	}
+
+	after, err := batches.ListSheets(id)
+	if err != nil {
+		t.Fatalf("ListSheets: %v", err)
+	}
+	if !slices.Equal(before, after) {
+		t.Fatalf("листы после отклонённого пакета %v, want %v", after, before)
+	}
+	if got, want := writeCalls(rec), []string{"BatchUpdate", "WriteValues", "BatchUpdate"}; !slices.Equal(got, want) {
+		t.Fatalf("вызовы %v, want %v", got, want)
+	}
+	if cached, _ := sc.cache.IsSupInCashed("USD", "soup2"); cached {
+		t.Fatal("лист soup2 попал в кэш")
+	}
+}
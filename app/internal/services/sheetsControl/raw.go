@@ -0,0 +1,94 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/models"
+	"fmt"
+	"google.golang.org/api/sheets/v4"
+	"regexp"
+	"strconv"
+)
+
+// rawSheetPattern - имена листов, на которые переносятся строки RAW сверх RawMaxRows
+var rawSheetPattern = regexp.MustCompile(`^RAW_(\d+)$`)
+
+// rawSheetName возвращает имя i-го (с 0) листа данных RAW: RAW, RAW_2, RAW_3...
+func rawSheetName(i int) string {
+	if i == 0 {
+		return "RAW"
+	}
+	return fmt.Sprintf("RAW_%d", i+1)
+}
+
+// isRawSheet сообщает, является ли лист листом данных RAW
+func isRawSheet(name string) bool {
+	return name == "RAW" || rawSheetPattern.MatchString(name)
+}
+
+// rawChunks делит строки RAW между листами по rawMaxRows строк. Листов не меньше, чем уже создано
+// для валюты: лишние листы получают пустую часть, и прежние данные в них очищаются
+func (sc *SheetsControl) rawChunks(fiat string, raw models.RAWData) ([]models.RAWData, error) {
+	count := 1
+	if sc.rawMaxRows > 0 && len(raw.Data) > sc.rawMaxRows {
+		count = (len(raw.Data) + sc.rawMaxRows - 1) / sc.rawMaxRows
+	}
+	sups, err := sc.cache.GetSupsByFiat(fiat)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sups {
+		if m := rawSheetPattern.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			count = max(count, n)
+		}
+	}
+
+	chunks := make([]models.RAWData, count)
+	for i := range chunks {
+		chunks[i].Date = raw.Date
+		switch {
+		case sc.rawMaxRows <= 0 && i == 0:
+			chunks[i].Data = raw.Data
+		case sc.rawMaxRows > 0:
+			start := min(i*sc.rawMaxRows, len(raw.Data))
+			end := min(start+sc.rawMaxRows, len(raw.Data))
+			chunks[i].Data = raw.Data[start:end]
+		}
+	}
+	return chunks, nil
+}
+
+// rawFilterNeed возвращает размер сетки RAW_filter, в который помещается результат формулы для rows строк
+// данных: формула выводит строку заголовка и строки данных начиная со своей ячейки
+func (sc *SheetsControl) rawFilterNeed(rows int) gridSize {
+	row, col, width, ok := sc.layout.RawFilterArea()
+	if !ok {
+		return gridSize{}
+	}
+	return gridSize{rows: int64(row + rows + 1), cols: int64(col + width)}
+}
+
+// rawFilterRequests создаёт лист RAW_filter с ID filterSheetID и сеткой не меньше need; он создаётся вместе
+// с листом RAW. Фильтр начинается в строке формулы, открыт вниз и охватывает столбцы, которые она выбирает
+func (sc *SheetsControl) rawFilterRequests(filterSheetID int64, need gridSize) []*sheets.Request {
+	grid := gridSize{rows: defaultGridRows, cols: defaultGridCols}.grow(need)
+	requests := []*sheets.Request{
+		{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{
+			SheetId: filterSheetID,
+			Title:   "RAW_filter",
+			GridProperties: &sheets.GridProperties{
+				RowCount:    grid.rows,
+				ColumnCount: grid.cols,
+			},
+		}}},
+	}
+	row, col, width, ok := sc.layout.RawFilterArea()
+	if !ok {
+		return requests
+	}
+	return append(requests, &sheets.Request{SetBasicFilter: &sheets.SetBasicFilterRequest{Filter: &sheets.BasicFilter{Range: &sheets.GridRange{
+		SheetId:          filterSheetID,
+		StartRowIndex:    int64(row),
+		StartColumnIndex: int64(col),
+		EndColumnIndex:   int64(col + width),
+	}}}})
+}
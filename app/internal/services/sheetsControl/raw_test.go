@@ -0,0 +1,86 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/models"
+	"strconv"
+	"testing"
+)
+
+// rawPayload возвращает rows строк RAW по 15 столбцов
+func rawPayload(rows int) models.RAWData {
+	raw := models.RAWData{Date: "2025-01-30"}
+	for i := range rows {
+		row := make([]string, 15)
+		for j := range row {
+			row[j] = strconv.Itoa(i*100 + j)
+		}
+		raw.Data = append(raw.Data, row)
+	}
+	return raw
+}
+
+// rawFilterRows возвращает число строк сетки RAW_filter валюты
+func rawFilterRows(t *testing.T, sc *SheetsControl, b *memoryBackend.MemoryBackend, fiat string) int64 {
+	t.Helper()
+	id, _ := sc.cache.GetIDbyFiat(fiat)
+	sheetList, err := b.ListSheets(id)
+	if err != nil {
+		t.Fatalf("ListSheets: %v", err)
+	}
+	for _, sheet := range sheetList {
+		if sheet.Title == "RAW_filter" {
+			return sheet.RowCount
+		}
+	}
+	t.Fatal("листа RAW_filter нет")
+	return 0
+}
+
+// Сетка RAW_filter растёт вместе с RAW, чтобы результат формулы в A6 помещался на лист,
+// а фильтр начинается в строке формулы, открыт вниз и охватывает 15 столбцов формулы
+func TestRawFilterGrowsWithRaw(t *testing.T) {
+	memory := memoryBackend.New()
+	batches := &batchBackend{SpreadsheetBackend: memory}
+	sc := newTestControl(t, batches, Options{})
+
+	raw := rawPayload(defaultGridRows + 500)
+	if _, err := sc.UpsertRaw("USD", raw); err != nil {
+		t.Fatalf("UpsertRaw: %v", err)
+	}
+	// Формула в строке 6 выводит заголовок и все строки данных
+	want := int64(5 + 1 + len(raw.Data))
+	if got := rawFilterRows(t, sc, memory, "USD"); got < want {
+		t.Fatalf("строк в RAW_filter %d, want не меньше %d", got, want)
+	}
+
+	var filterFound bool
+	for _, request := range batches.batches[0] {
+		if request.SetBasicFilter == nil {
+			continue
+		}
+		filterFound = true
+		rng := request.SetBasicFilter.Filter.Range
+		if rng.StartRowIndex != 5 || rng.EndRowIndex != 0 || rng.StartColumnIndex != 0 || rng.EndColumnIndex != 15 {
+			t.Fatalf("диапазон фильтра %+v, want строки с 5 до конца листа и столбцы 0..15", rng)
+		}
+	}
+	if !filterFound {
+		t.Fatal("фильтр RAW_filter не создан")
+	}
+
+	// Лист RAW_filter уже есть: он увеличивается при следующей записи и при дозаписи
+	raw = rawPayload(defaultGridRows * 2)
+	if _, err := sc.UpsertRaw("USD", raw); err != nil {
+		t.Fatalf("UpsertRaw: %v", err)
+	}
+	if got, want := rawFilterRows(t, sc, memory, "USD"), int64(6+len(raw.Data)); got < want {
+		t.Fatalf("строк в RAW_filter после записи %d, want не меньше %d", got, want)
+	}
+	if _, err := sc.AppendRaw("USD", rawPayload(300)); err != nil {
+		t.Fatalf("AppendRaw: %v", err)
+	}
+	if got, want := rawFilterRows(t, sc, memory, "USD"), int64(6+len(raw.Data)+300); got < want {
+		t.Fatalf("строк в RAW_filter после дозаписи %d, want не меньше %d", got, want)
+	}
+}
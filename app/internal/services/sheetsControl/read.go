@@ -0,0 +1,83 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ReadSoup читает лист супа и разбирает его обратно в models.Soup по раскладке листа
+func (sc *SheetsControl) ReadSoup(fiat, sheetName string) (models.Soup, error) {
+	if isRawSheet(sheetName) || sheetName == "RAW_filter" {
+		return models.Soup{}, &apperrors.ValidationError{Field: "sheetName", Msg: "лист RAW читается через /api/sheets/{fiat}/raw"}
+	}
+	spreadsheetID, err := sc.spreadsheetID(fiat)
+	if err != nil {
+		return models.Soup{}, err
+	}
+
+	rows, err := sc.backend.ReadValues(spreadsheetID, sheetName)
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения листа", "fiat", fiat, "sheetName", sheetName, "error", err)
+		return models.Soup{}, err
+	}
+	soup := models.Soup{Name: sheetName}
+	if err := sc.layout.Soup.Parse(rows, &soup); err != nil {
+		return models.Soup{}, fmt.Errorf("лист %s не соответствует раскладке: %w", sheetName, err)
+	}
+	return soup, nil
+}
+
+// ReadRaw читает лист RAW вместе с листами RAW_2, RAW_3... и собирает строки данных в порядке листов
+func (sc *SheetsControl) ReadRaw(fiat string) (models.RAWData, error) {
+	spreadsheetID, err := sc.spreadsheetID(fiat)
+	if err != nil {
+		return models.RAWData{}, err
+	}
+	sups, err := sc.cache.GetSupsByFiat(fiat)
+	if err != nil {
+		return models.RAWData{}, err
+	}
+	var chunks []int
+	for _, name := range sups {
+		if m := rawSheetPattern.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			chunks = append(chunks, n)
+		}
+	}
+	sort.Ints(chunks)
+
+	names := []string{"RAW"}
+	for _, n := range chunks {
+		names = append(names, rawSheetName(n-1))
+	}
+	result := models.RAWData{Data: [][]string{}}
+	for i, name := range names {
+		rows, err := sc.backend.ReadValues(spreadsheetID, name)
+		if err != nil {
+			sc.log.Errorw("Ошибка чтения листа", "fiat", fiat, "sheetName", name, "error", err)
+			return models.RAWData{}, err
+		}
+		var chunk models.RAWData
+		if err := sc.layout.Raw.Parse(rows, &chunk); err != nil {
+			return models.RAWData{}, fmt.Errorf("лист %s не соответствует раскладке: %w", name, err)
+		}
+		if i == 0 {
+			result.Date = chunk.Date
+		}
+		result.Data = append(result.Data, chunk.Data...)
+	}
+	return result, nil
+}
+
+// spreadsheetID возвращает ID таблицы валюты из кэша; apperrors.ErrSpreadsheetNotFound, если таблицы нет
+func (sc *SheetsControl) spreadsheetID(fiat string) (string, error) {
+	id, err := sc.cache.GetIDbyFiat(fiat)
+	if errors.Is(err, apperrors.ErrCacheNotFound) {
+		return "", fmt.Errorf("таблица для %s не найдена: %w", fiat, apperrors.ErrSpreadsheetNotFound)
+	}
+	return id, err
+}
@@ -0,0 +1,315 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/models"
+	"slices"
+	"sort"
+	"time"
+)
+
+// startReconcile периодически сверяет кэш с хранилищем
+func (sc *SheetsControl) startReconcile(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sc.ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := sc.Reconcile(); err != nil {
+					sc.log.Errorw("Ошибка периодической сверки кэша", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Reconcile сверяет кэш с хранилищем: добавляет таблицы сервиса и листы, созданные вне сервиса,
+// и удаляет те, которых больше нет. Таблицы без метки сервиса пропускаются. Возвращает отчёт об изменениях
+func (sc *SheetsControl) Reconcile() (models.ReconcileReport, error) {
+	sc.reconcileMu.Lock()
+	defer sc.reconcileMu.Unlock()
+
+	report := models.ReconcileReport{
+		StartedAt:           time.Now(),
+		AddedSpreadsheets:   []string{},
+		RemovedSpreadsheets: []string{},
+		ChangedSpreadsheets: []string{},
+		AddedSheets:         map[string][]string{},
+		RemovedSheets:       map[string][]string{},
+
+		UntaggedSpreadsheets:  []string{},
+		AdoptedSpreadsheets:   []string{},
+		DuplicateSpreadsheets: map[string][]string{},
+	}
+
+	// Снимок кэша берётся до запроса к хранилищу: таблицы, созданные во время сверки,
+	// не попадут в снимок и не будут удалены
+	cachedIDs, err := sc.cache.GetAllIDs()
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения кэша таблиц", "error", err)
+		return report, err
+	}
+
+	// Получаем список всех таблиц
+	spreadsheets, err := sc.backend.ListSpreadsheets()
+	if err != nil {
+		sc.log.Errorw("Ошибка получения id таблиц", "error", err)
+		return report, err
+	}
+	sheetIDMap := sc.managedSpreadsheets(spreadsheets, cachedIDs, &report)
+	report.Spreadsheets = len(sheetIDMap)
+
+	if err := sc.reconcileSpreadsheets(cachedIDs, sheetIDMap, &report); err != nil {
+		return report, err
+	}
+
+	// Проходим по всем таблицам и сверяем списки листов
+	for fiat, spreadsheetID := range sheetIDMap {
+		sc.reconcileSheets(fiat, spreadsheetID, &report)
+	}
+
+	sort.Strings(report.AddedSpreadsheets)
+	sort.Strings(report.RemovedSpreadsheets)
+	sort.Strings(report.ChangedSpreadsheets)
+	sort.Strings(report.UntaggedSpreadsheets)
+	sort.Strings(report.AdoptedSpreadsheets)
+	report.FinishedAt = time.Now()
+
+	if report.Changed() {
+		sc.log.Infow("Кэш сверен с хранилищем, есть изменения",
+			"added_spreadsheets", report.AddedSpreadsheets,
+			"removed_spreadsheets", report.RemovedSpreadsheets,
+			"changed_spreadsheets", report.ChangedSpreadsheets,
+			"added_sheets", report.AddedSheets,
+			"removed_sheets", report.RemovedSheets)
+	} else {
+		sc.log.Infow("Кэш сверен с хранилищем, изменений нет", "spreadsheets", report.Spreadsheets)
+	}
+	return report, nil
+}
+
+// managedSpreadsheets выбирает таблицы сервиса по метке и возвращает соответствие fiat -> ID.
+// Если для валюты нашлось несколько таблиц, используется таблица из кэша (иначе первая по ID),
+// а все ID попадают в отчёт. Таблица без метки, уже записанная в кэш, остаётся за своей валютой:
+// например, после обновления сервиса метки ещё не проставлены. При включённом adoptUntagged таким
+// таблицам и таблицам без метки с однозначным названием добавляется метка
+func (sc *SheetsControl) managedSpreadsheets(spreadsheets []backend.SpreadsheetInfo, cachedIDs map[string]string, report *models.ReconcileReport) map[string]string {
+	cachedFiats := make(map[string]string, len(cachedIDs)) // ID -> fiat
+	for fiat, spreadsheetID := range cachedIDs {
+		cachedFiats[spreadsheetID] = fiat
+	}
+
+	byFiat := make(map[string][]string)
+	untagged := make(map[string][]string) // название -> ID
+	cachedUntagged := make(map[string]string)
+	for _, spreadsheet := range spreadsheets {
+		if spreadsheet.Managed && spreadsheet.Fiat != "" {
+			byFiat[spreadsheet.Fiat] = append(byFiat[spreadsheet.Fiat], spreadsheet.ID)
+			continue
+		}
+		report.UntaggedSpreadsheets = append(report.UntaggedSpreadsheets, spreadsheet.Title)
+		if fiat, ok := cachedFiats[spreadsheet.ID]; ok {
+			cachedUntagged[fiat] = spreadsheet.ID
+			continue
+		}
+		untagged[spreadsheet.Title] = append(untagged[spreadsheet.Title], spreadsheet.ID)
+	}
+
+	for fiat, spreadsheetID := range cachedUntagged {
+		if len(byFiat[fiat]) > 0 {
+			// Для валюты есть таблица с меткой: она главнее, а таблица из кэша попадает в дубликаты
+			report.DuplicateSpreadsheets[fiat] = append(slices.Clone(byFiat[fiat]), spreadsheetID)
+			continue
+		}
+		byFiat[fiat] = []string{spreadsheetID}
+		if !sc.adoptUntagged {
+			continue
+		}
+		if err := sc.backend.TagSpreadsheet(spreadsheetID, fiat); err != nil {
+			sc.log.Warnw("Ошибка добавления метки таблице", "fiat", fiat, "spreadsheetID", spreadsheetID, "error", err)
+			report.Errors = append(report.Errors, fiat+": "+err.Error())
+			continue
+		}
+		sc.log.Infow("Таблице из кэша добавлена метка сервиса", "fiat", fiat, "spreadsheetID", spreadsheetID)
+		report.AdoptedSpreadsheets = append(report.AdoptedSpreadsheets, fiat)
+	}
+
+	if sc.adoptUntagged {
+		for title, ids := range untagged {
+			if len(byFiat[title]) > 0 || len(ids) > 1 {
+				// Неоднозначное название: таблица не присваивается, чтобы не выбрать чужую
+				sc.log.Warnw("Таблица без метки не присвоена: название неоднозначно",
+					"title", title,
+					"spreadsheet_ids", ids,
+					"managed_ids", byFiat[title])
+				report.DuplicateSpreadsheets[title] = append(slices.Clone(byFiat[title]), ids...)
+				continue
+			}
+			if err := sc.backend.TagSpreadsheet(ids[0], title); err != nil {
+				sc.log.Warnw("Ошибка добавления метки таблице", "title", title, "spreadsheetID", ids[0], "error", err)
+				report.Errors = append(report.Errors, title+": "+err.Error())
+				continue
+			}
+			sc.log.Infow("Таблица без метки присвоена сервису", "fiat", title, "spreadsheetID", ids[0])
+			byFiat[title] = ids
+			report.AdoptedSpreadsheets = append(report.AdoptedSpreadsheets, title)
+		}
+	}
+
+	sheetIDMap := make(map[string]string, len(byFiat))
+	for fiat, ids := range byFiat {
+		sort.Strings(ids)
+		chosen := ids[0]
+		if len(ids) > 1 {
+			if slices.Contains(ids, cachedIDs[fiat]) {
+				chosen = cachedIDs[fiat]
+			}
+			sc.log.Warnw("Найдено несколько таблиц для одной валюты",
+				"fiat", fiat,
+				"spreadsheet_ids", ids,
+				"used", chosen)
+			report.DuplicateSpreadsheets[fiat] = ids
+		}
+		sheetIDMap[fiat] = chosen
+	}
+	return sheetIDMap
+}
+
+// reconcileSpreadsheets приводит соответствие валют и ID таблиц в кэше к списку из хранилища
+func (sc *SheetsControl) reconcileSpreadsheets(cachedIDs, sheetIDMap map[string]string, report *models.ReconcileReport) error {
+	toAdd := make(map[string]string)
+	for fiat, spreadsheetID := range sheetIDMap {
+		cachedID, ok := cachedIDs[fiat]
+		switch {
+		case !ok:
+			report.AddedSpreadsheets = append(report.AddedSpreadsheets, fiat)
+		case cachedID != spreadsheetID:
+			// Таблицу пересоздали вне сервиса: листы старой таблицы больше не актуальны
+			if err := sc.cache.RemoveFiatFromCache(fiat); err != nil {
+				sc.log.Errorw("Ошибка удаления таблицы из кэша", "fiat", fiat, "error", err)
+				return err
+			}
+			report.ChangedSpreadsheets = append(report.ChangedSpreadsheets, fiat)
+		default:
+			continue
+		}
+		toAdd[fiat] = spreadsheetID
+	}
+
+	// Добавляем информацию о таблицах в кэш
+	if err := sc.cache.AddToCash(toAdd); err != nil {
+		sc.log.Errorw("Ошибка инициализации кеша таблиц", "error", err)
+		return err
+	}
+
+	for fiat, cachedID := range cachedIDs {
+		if _, ok := sheetIDMap[fiat]; ok {
+			continue
+		}
+		// Запись могла обновиться после снимка, например при создании таблицы заново
+		if currentID, err := sc.cache.GetIDbyFiat(fiat); err != nil || currentID != cachedID {
+			continue
+		}
+		if err := sc.cache.RemoveFiatFromCache(fiat); err != nil {
+			sc.log.Errorw("Ошибка удаления таблицы из кэша", "fiat", fiat, "error", err)
+			return err
+		}
+		report.RemovedSpreadsheets = append(report.RemovedSpreadsheets, fiat)
+	}
+	return nil
+}
+
+// reconcileSheets приводит список листов валюты в кэше к списку из хранилища
+func (sc *SheetsControl) reconcileSheets(fiat, spreadsheetID string, report *models.ReconcileReport) {
+	added, removed, err := sc.syncSheets(fiat, spreadsheetID)
+	if err != nil {
+		report.Errors = append(report.Errors, fiat+": "+err.Error())
+		return
+	}
+	if len(added) > 0 {
+		report.AddedSheets[fiat] = added
+	}
+	if len(removed) > 0 {
+		report.RemovedSheets[fiat] = removed
+	}
+}
+
+// syncSheets приводит список листов валюты в кэше к списку из хранилища и запоминает ID и размеры сеток листов.
+// Возвращает добавленные в кэш и удалённые из кэша листы
+func (sc *SheetsControl) syncSheets(fiat, spreadsheetID string) (added, removed []string, err error) {
+	cachedSups, err := sc.cache.GetSupsByFiat(fiat)
+	if err != nil {
+		sc.log.Warnw("Ошибка чтения листов из кэша", "fiat", fiat, "error", err)
+		return nil, nil, err
+	}
+
+	// Получаем список всех листов в таблице
+	sheetList, err := sc.backend.ListSheets(spreadsheetID)
+	if err != nil {
+		sc.log.Warnw("Ошибка получения списка листов для таблицы",
+			"fiat", fiat,
+			"spreadsheetID", spreadsheetID,
+			"error", err)
+		return nil, nil, err
+	}
+
+	remote := make(map[string]bool, len(sheetList))
+	for _, sheet := range sheetList {
+		remote[sheet.Title] = true
+	}
+	cached := make(map[string]bool, len(cachedSups))
+	for _, sheetName := range cachedSups {
+		cached[sheetName] = true
+	}
+
+	for _, sheet := range sheetList {
+		sc.rememberSheet(fiat, sheet)
+		if cached[sheet.Title] {
+			continue
+		}
+		if err := sc.cache.SetSupInCashed(fiat, sheet.Title); err != nil {
+			sc.log.Warnw("Ошибка добавления листа в кэш", "fiat", fiat, "sheetName", sheet.Title, "error", err)
+			continue
+		}
+		added = append(added, sheet.Title)
+	}
+	for _, sheetName := range cachedSups {
+		if remote[sheetName] {
+			continue
+		}
+		if err := sc.cache.RemoveSupFromCashed(fiat, sheetName); err != nil {
+			sc.log.Warnw("Ошибка удаления листа из кэша", "fiat", fiat, "sheetName", sheetName, "error", err)
+			continue
+		}
+		removed = append(removed, sheetName)
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed, nil
+}
+
+// rememberSheet обновляет ID и размер сетки листа в кэше, сохраняя сведения о последней записи
+func (sc *SheetsControl) rememberSheet(fiat string, sheet backend.SheetInfo) {
+	state, _, err := sc.cache.GetSheetState(fiat, sheet.Title)
+	if err != nil {
+		sc.log.Warnw("Ошибка чтения состояния листа из кэша", "fiat", fiat, "sheetName", sheet.Title, "error", err)
+		return
+	}
+	id := sheet.SheetID
+	if state.SheetID != nil && *state.SheetID == id && state.GridRows == sheet.RowCount && state.GridCols == sheet.ColumnCount {
+		return
+	}
+	state.SheetID = &id
+	state.GridRows = sheet.RowCount
+	state.GridCols = sheet.ColumnCount
+	if err := sc.cache.SetSheetState(fiat, sheet.Title, state); err != nil {
+		sc.log.Warnw("Ошибка сохранения состояния листа в кэш", "fiat", fiat, "sheetName", sheet.Title, "error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/models"
+	"errors"
+)
+
+// writeWithRepair записывает данные. Если таблица или лист были удалены вручную,
+// устаревшие записи кэша сбрасываются, и запись повторяется один раз: недостающие
+// таблица и листы создаются заново. Неудавшаяся дозапись строк RAW не повторяется:
+// строки могли быть дописаны, и это выясняет только повтор из журнала
+func (sc *SheetsControl) writeWithRepair(b backend.SpreadsheetBackend, data models.SheetData, scope models.WriteScope, seq uint64) error {
+	err := sc.setSheetData(b, data, scope, seq)
+	var stepErr *apperrors.StepError
+	if errors.As(err, &stepErr) && stepErr.Step == StepAppendValues {
+		return err
+	}
+	if err == nil || !sc.repairCache(b, data, err) {
+		return err
+	}
+
+	sc.log.Infow("Повтор записи после восстановления кэша", "fiat", data.Fiat)
+	if err := sc.setSheetData(b, data, scope, seq); err != nil {
+		sc.log.Errorw("Повторная запись после восстановления кэша не удалась", "fiat", data.Fiat, "error", err)
+		return err
+	}
+	sc.log.Infow("Запись выполнена после восстановления кэша", "fiat", data.Fiat)
+	return nil
+}
+
+// repairCache сбрасывает записи кэша, которые больше не соответствуют хранилищу.
+// Возвращает true, если что-то исправлено и запись имеет смысл повторить
+func (sc *SheetsControl) repairCache(b backend.SpreadsheetBackend, data models.SheetData, cause error) bool {
+	switch {
+	case errors.Is(cause, apperrors.ErrSpreadsheetNotFound):
+		return sc.forgetSpreadsheet(data.Fiat, cause)
+	case errors.Is(cause, apperrors.ErrSheetNotFound):
+		return sc.forgetMissingSheets(b, data)
+	default:
+		return false
+	}
+}
+
+// forgetSpreadsheet удаляет из кэша таблицу, которой больше нет в хранилище
+func (sc *SheetsControl) forgetSpreadsheet(fiat string, cause error) bool {
+	spreadsheetID, _ := sc.cache.GetIDbyFiat(fiat)
+	if err := sc.cache.RemoveFiatFromCache(fiat); err != nil {
+		sc.log.Errorw("Ошибка удаления таблицы из кэша", "fiat", fiat, "error", err)
+		return false
+	}
+	sc.log.Warnw("Таблица удалена вне сервиса, запись в кэше сброшена",
+		"fiat", fiat,
+		"spreadsheetID", spreadsheetID,
+		"error", cause)
+	return true
+}
+
+// forgetMissingSheets сверяет листы из данных с хранилищем и удаляет из кэша отсутствующие.
+// Сами листы в хранилище не трогаются: уцелевший RAW_filter остаётся, и при записи создаётся только RAW
+func (sc *SheetsControl) forgetMissingSheets(b backend.SpreadsheetBackend, data models.SheetData) bool {
+	spreadsheetID, err := sc.cache.GetIDbyFiat(data.Fiat)
+	if err != nil {
+		return false
+	}
+	sheetList, err := b.ListSheets(spreadsheetID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrSpreadsheetNotFound) {
+			return sc.forgetSpreadsheet(data.Fiat, err)
+		}
+		sc.log.Errorw("Ошибка получения списка листов для восстановления кэша", "fiat", data.Fiat, "error", err)
+		return false
+	}
+	existing := make(map[string]bool, len(sheetList))
+	for _, sheet := range sheetList {
+		existing[sheet.Title] = true
+	}
+
+	names := make([]string, 0, len(data.SoupList)+1)
+	for _, soup := range data.SoupList {
+		names = append(names, soup.Name)
+	}
+	cachedSups, err := sc.cache.GetSupsByFiat(data.Fiat)
+	if err != nil {
+		return false
+	}
+	for _, name := range cachedSups {
+		if isRawSheet(name) {
+			names = append(names, name)
+		}
+	}
+
+	repaired := false
+	for _, name := range names {
+		cached, err := sc.cache.IsSupInCashed(data.Fiat, name)
+		if err != nil || !cached || existing[name] {
+			continue
+		}
+		if err := sc.cache.RemoveSupFromCashed(data.Fiat, name); err != nil {
+			sc.log.Errorw("Ошибка удаления листа из кэша", "fiat", data.Fiat, "sheetName", name, "error", err)
+			return false
+		}
+		sc.log.Warnw("Лист удалён вне сервиса, запись в кэше сброшена", "fiat", data.Fiat, "sheetName", name)
+		repaired = true
+	}
+	return repaired
+}
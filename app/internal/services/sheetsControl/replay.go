@@ -0,0 +1,156 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/models"
+	"errors"
+	"time"
+)
+
+// OutcomeDeferred - запись не удалась, данные сохранены в журнале исходящих данных и будут отправлены повторно
+const OutcomeDeferred WriteOutcome = "deferred"
+
+// errAppendQueued - дописываемые строки RAW ждут отправки более ранних строк той же валюты
+var errAppendQueued = errors.New("в журнале есть более ранние строки RAW этой валюты")
+
+// saveToOutbox сохраняет данные на диск до отправки. Возвращает 0, если журнал отключён
+func (sc *SheetsControl) saveToOutbox(data models.SheetData, scope models.WriteScope) (uint64, error) {
+	if sc.outbox == nil {
+		return 0, nil
+	}
+	seq, err := sc.outbox.Put(data, scope)
+	if err != nil {
+		sc.log.Errorw("Ошибка сохранения данных в журнал исходящих данных", "fiat", data.Fiat, "error", err)
+		return 0, err
+	}
+	return seq, nil
+}
+
+// deliver записывает данные в порядке очереди их ключа и отмечает результат в журнале исходящих данных.
+// Записи одной валюты с разными ключами не заменяют друг друга, но выполняются по одной.
+// Дописываемые строки RAW не заменяются более новыми и записываются все в порядке поступления:
+// пока в журнале остаются более ранние строки валюты, новые откладываются. Данные из журнала,
+// которые к началу записи заменены более новыми с тем же ключом, не записываются и подтверждаются.
+// Данные, которые нельзя записать и при повторе (ошибка проверки, конфликт дозаписи), удаляются из журнала
+func (sc *SheetsControl) deliver(b backend.SpreadsheetBackend, data models.SheetData, scope models.WriteScope, seq uint64) (WriteOutcome, error) {
+	superseded := false
+	write := func() error {
+		unlock := sc.fiats.Lock(data.Fiat)
+		defer unlock()
+		if sc.outbox != nil && seq != 0 && !sc.outbox.IsLatest(seq) {
+			superseded = true
+			return nil
+		}
+		if sc.outbox != nil && seq != 0 && !scope.Coalesces() && sc.outbox.HasEarlier(seq) {
+			return errAppendQueued
+		}
+		return sc.writeWithRepair(b, data, scope, seq)
+	}
+	outcome, err := OutcomeWritten, error(nil)
+	if scope.Coalesces() {
+		outcome, err = sc.writes.do(scope.Key(data), write)
+	} else {
+		err = write()
+	}
+	if superseded {
+		outcome = OutcomeSuperseded
+	}
+	if outcome == OutcomeSuperseded {
+		sc.log.Infow("Данные заменены более новыми до начала записи", "fiat", data.Fiat, "scope", scope)
+	}
+	if sc.outbox == nil || seq == 0 {
+		return outcome, err
+	}
+
+	var validationErr *apperrors.ValidationError
+	if errors.As(err, &validationErr) || errors.Is(err, apperrors.ErrAppendConflict) {
+		sc.log.Errorw("Данные не могут быть записаны и удалены из журнала", "fiat", data.Fiat, "seq", seq, "error", err)
+		if ackErr := sc.outbox.Ack(seq); ackErr != nil {
+			sc.log.Errorw("Ошибка подтверждения отправки в журнале", "fiat", data.Fiat, "seq", seq, "error", ackErr)
+		}
+		return OutcomeFailed, err
+	}
+	if err != nil {
+		sc.outbox.Fail(seq, err)
+		sc.log.Warnw("Данные оставлены в журнале для повторной отправки", "fiat", data.Fiat, "seq", seq, "error", err)
+		return OutcomeDeferred, err
+	}
+	if ackErr := sc.outbox.Ack(seq); ackErr != nil {
+		sc.log.Errorw("Ошибка подтверждения отправки в журнале", "fiat", data.Fiat, "seq", seq, "error", ackErr)
+	}
+	return outcome, nil
+}
+
+// startOutboxReplay отправляет данные, оставшиеся в журнале после сбоя или перезапуска,
+// сразу и затем с заданным интервалом
+func (sc *SheetsControl) startOutboxReplay(interval time.Duration) {
+	if sc.outbox == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		sc.replayOutbox()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sc.ctx.Done():
+				return
+			case <-ticker.C:
+				sc.replayOutbox()
+			}
+		}
+	}()
+}
+
+// replayOutbox по очереди отправляет неподтверждённые данные в порядке поступления
+func (sc *SheetsControl) replayOutbox() {
+	for _, pending := range sc.outbox.Pending() {
+		entry, ok := sc.outbox.Claim(pending.Seq)
+		if !ok {
+			continue
+		}
+
+		sc.log.Infow("Повторная отправка данных из журнала",
+			"fiat", entry.Data.Fiat,
+			"seq", entry.Seq,
+			"attempts", entry.Attempts)
+		if _, err := sc.deliver(sc.backend, entry.Data, entry.Scope, entry.Seq); err != nil {
+			continue
+		}
+		sc.log.Infow("Данные из журнала отправлены", "fiat", entry.Data.Fiat, "seq", entry.Seq)
+	}
+}
+
+// PendingOutbox возвращает неотправленные данные без содержимого таблиц
+func (sc *SheetsControl) PendingOutbox() []models.OutboxEntry {
+	if sc.outbox == nil {
+		return []models.OutboxEntry{}
+	}
+
+	pending := sc.outbox.Pending()
+	result := make([]models.OutboxEntry, 0, len(pending))
+	for _, entry := range pending {
+		soups := make([]string, 0, len(entry.Data.SoupList))
+		for _, soup := range entry.Data.SoupList {
+			soups = append(soups, soup.Name)
+		}
+		result = append(result, models.OutboxEntry{
+			Seq:        entry.Seq,
+			Fiat:       entry.Data.Fiat,
+			Scope:      entry.Scope,
+			EnqueuedAt: entry.EnqueuedAt,
+			Attempts:   entry.Attempts,
+			LastError:  entry.LastError,
+			InFlight:   entry.InFlight,
+			Sheets:     soups,
+			RawRows:    len(entry.Data.RAWData.Data),
+		})
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/models"
+	"GoogleSheetW/internal/outbox"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// blockingBackend задерживает создание таблиц, пока не закрыт release, и сообщает о начале создания в started
+type blockingBackend struct {
+	backend.SpreadsheetBackend
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) CreateSpreadsheet(title string) (string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.SpreadsheetBackend.CreateSpreadsheet(title)
+}
+
+// Данные из журнала, заменённые более новыми после Claim, не записываются поверх новых
+func TestDeliverSkipsSupersededOutboxEntry(t *testing.T) {
+	journal, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.log"))
+	if err != nil {
+		t.Fatalf("outbox.Open: %v", err)
+	}
+	defer journal.Close()
+
+	b := memoryBackend.New()
+	sc := newTestControl(t, b, Options{Outbox: journal})
+
+	stale := testSheetData("USD", "1")
+	staleSeq, err := journal.Put(stale, models.ScopeAll)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	journal.Fail(staleSeq, errTest)
+	entry, ok := journal.Claim(staleSeq)
+	if !ok {
+		t.Fatal("Claim не удался")
+	}
+
+	fresh := testSheetData("USD", "2")
+	freshSeq, err := journal.Put(fresh, models.ScopeAll)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	outcome, err := sc.deliver(b, entry.Data, entry.Scope, entry.Seq)
+	if err != nil || outcome != OutcomeSuperseded {
+		t.Fatalf("deliver устаревших данных = %s, %v; want superseded", outcome, err)
+	}
+	if _, err := sc.cache.GetIDbyFiat("USD"); err == nil {
+		t.Fatal("устаревшие данные записаны")
+	}
+
+	outcome, err = sc.deliver(b, fresh, models.ScopeAll, freshSeq)
+	if err != nil || outcome != OutcomeWritten {
+		t.Fatalf("deliver новых данных = %s, %v", outcome, err)
+	}
+	soup, err := sc.ReadSoup("USD", "soup")
+	if err != nil {
+		t.Fatalf("ReadSoup: %v", err)
+	}
+	if got := soup.Data[1][1]; got != "2" {
+		t.Fatalf("в листе %q, want 2", got)
+	}
+	if n := journal.Len(); n != 0 {
+		t.Fatalf("в журнале осталось %d записей", n)
+	}
+}
+
+// Задача, отклонённая из-за заполненной очереди, не оставляет данных в журнале
+func TestRejectedJobIsNotJournaled(t *testing.T) {
+	b := &blockingBackend{SpreadsheetBackend: memoryBackend.New(), started: make(chan struct{}, 3), release: make(chan struct{})}
+	sc, journal := newAppendControl(t, b, Options{JobWorkers: 1, JobQueueSize: 1})
+	defer close(b.release)
+
+	// Первую задачу выполняет обработчик, вторая занимает единственное место в очереди
+	if _, err := sc.SubmitSheetData(testSheetData("USD", "1")); err != nil {
+		t.Fatalf("SubmitSheetData: %v", err)
+	}
+	<-b.started
+	if _, err := sc.SubmitSheetData(testSheetData("EUR", "1")); err != nil {
+		t.Fatalf("SubmitSheetData: %v", err)
+	}
+
+	if _, err := sc.SubmitSheetData(testSheetData("GBP", "1")); !errors.Is(err, apperrors.ErrJobQueueFull) {
+		t.Fatalf("err = %v, want %v", err, apperrors.ErrJobQueueFull)
+	}
+	for _, entry := range journal.Pending() {
+		if entry.Data.Fiat == "GBP" {
+			t.Fatal("данные отклонённой задачи остались в журнале")
+		}
+	}
+	if n := journal.Len(); n != 2 {
+		t.Fatalf("в журнале %d записей, want 2", n)
+	}
+}
@@ -0,0 +1,101 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"errors"
+	"go.uber.org/zap"
+)
+
+// Шаги записи данных; имя шага, на котором запись прервалась, возвращается в ответе API
+const (
+	StepGetSpreadsheet    = "get_spreadsheet"
+	StepCreateSpreadsheet = "create_spreadsheet"
+	StepTagSpreadsheet    = "tag_spreadsheet"
+	StepPlaceSpreadsheet  = "place_spreadsheet"
+	StepAddPermission     = "add_permission"
+	StepPlanWrite         = "plan_write"
+	StepUpdateStructure   = "update_structure"
+	StepWriteValues       = "write_values"
+	StepReadRaw           = "read_raw"
+	StepAppendValues      = "append_values"
+	StepUpdateCache       = "update_cache"
+)
+
+// saga выполняет шаги записи по порядку. Шаг, который создаёт таблицу или листы, регистрирует компенсацию;
+// при ошибке компенсации выполняются в обратном порядке. Если отменить изменения не удалось или выполненный
+// шаг изменил данные без компенсации (например, очистил ячейки), ошибка помечается RolledBack=false:
+// остатки подбирает следующая запись (листы ищутся по списку листов таблицы)
+type saga struct {
+	log          *zap.SugaredLogger
+	fiat         string
+	undo         []compensation
+	committed    bool
+	irreversible bool
+}
+
+type compensation struct {
+	step string
+	undo func() error
+}
+
+func newSaga(log *zap.SugaredLogger, fiat string) *saga {
+	return &saga{log: log, fiat: fiat}
+}
+
+// run выполняет шаг; при ошибке отменяет выполненные шаги и возвращает *apperrors.StepError
+func (s *saga) run(step string, do func() error) error {
+	if err := do(); err != nil {
+		return s.fail(step, err)
+	}
+	return nil
+}
+
+// compensate регистрирует отмену изменений выполненного шага
+func (s *saga) compensate(step string, undo func() error) {
+	s.undo = append(s.undo, compensation{step: step, undo: undo})
+}
+
+// keep отменяет компенсации шага step: его результат остаётся, даже если следующие шаги не удадутся
+func (s *saga) keep(step string) {
+	undo := s.undo[:0]
+	for _, c := range s.undo {
+		if c.step != step {
+			undo = append(undo, c)
+		}
+	}
+	s.undo = undo
+}
+
+// irreversibleChange отмечает, что выполненный шаг изменил данные, которые компенсации не восстанавливают
+func (s *saga) irreversibleChange() {
+	s.irreversible = true
+}
+
+// commit отмечает, что данные записаны: после этого ошибки не отменяют запись
+func (s *saga) commit() {
+	s.committed = true
+	s.undo = nil
+}
+
+func (s *saga) fail(step string, err error) error {
+	var stepErr *apperrors.StepError
+	if errors.As(err, &stepErr) {
+		// Ошибка вложенного шага (например, при создании таблицы) уже обработана
+		return err
+	}
+
+	rolledBack := !s.committed && !s.irreversible
+	for i := len(s.undo) - 1; i >= 0; i-- {
+		c := s.undo[i]
+		if undoErr := c.undo(); undoErr != nil {
+			rolledBack = false
+			s.log.Errorw("Не удалось отменить шаг записи", "fiat", s.fiat, "step", c.step, "error", undoErr)
+			continue
+		}
+		s.log.Infow("Шаг записи отменён", "fiat", s.fiat, "step", c.step)
+	}
+	s.undo = nil
+
+	s.log.Errorw("Запись прервана", "fiat", s.fiat, "step", step, "rolledBack", rolledBack, "error", err)
+	return &apperrors.StepError{Step: step, Err: err, RolledBack: rolledBack}
+}
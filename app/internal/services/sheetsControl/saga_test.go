@@ -0,0 +1,86 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend/memoryBackend"
+	"GoogleSheetW/internal/models"
+	"errors"
+	"testing"
+)
+
+// Настроенная таблица остаётся после неудачной записи: повтор пишет в неё и не выдаёт доступ заново
+func TestFailedWriteKeepsConfiguredSpreadsheet(t *testing.T) {
+	b := &faultyBackend{SpreadsheetBackend: memoryBackend.New()}
+	sc := newTestControl(t, b, Options{})
+
+	b.failWriteValues.Store(true)
+	err := sc.setSheetData(b, testSheetData("USD", "1"), models.ScopeAll, 0)
+	var stepErr *apperrors.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepWriteValues {
+		t.Fatalf("err = %v, want шаг %s", err, StepWriteValues)
+	}
+	spreadsheets, _ := b.ListSpreadsheets()
+	if len(spreadsheets) != 1 {
+		t.Fatalf("таблиц %d, want 1", len(spreadsheets))
+	}
+	firstID, err := sc.cache.GetIDbyFiat("USD")
+	if err != nil || firstID != spreadsheets[0].ID {
+		t.Fatalf("в кэше %q, %v; want %s", firstID, err, spreadsheets[0].ID)
+	}
+
+	b.failWriteValues.Store(false)
+	if err := sc.setSheetData(b, testSheetData("USD", "1"), models.ScopeAll, 0); err != nil {
+		t.Fatalf("повторная запись: %v", err)
+	}
+	spreadsheets, _ = b.ListSpreadsheets()
+	if len(spreadsheets) != 1 || spreadsheets[0].ID != firstID {
+		t.Fatalf("после повтора таблицы %v, want только %s", spreadsheets, firstID)
+	}
+	if n := b.permissions.Load(); n != 1 {
+		t.Fatalf("доступ выдан %d раз, want 1", n)
+	}
+}
+
+// Таблица, которую не удалось настроить, удаляется
+func TestFailedPermissionDeletesSpreadsheet(t *testing.T) {
+	b := &faultyBackend{SpreadsheetBackend: memoryBackend.New()}
+	sc := newTestControl(t, b, Options{})
+
+	b.failAddPermission.Store(true)
+	err := sc.setSheetData(b, testSheetData("USD", "1"), models.ScopeAll, 0)
+	var stepErr *apperrors.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepAddPermission || !stepErr.RolledBack {
+		t.Fatalf("err = %#v, want шаг %s с отменой", err, StepAddPermission)
+	}
+	if spreadsheets, _ := b.ListSpreadsheets(); len(spreadsheets) != 0 {
+		t.Fatalf("осталось таблиц: %d", len(spreadsheets))
+	}
+	if _, err := sc.cache.GetIDbyFiat("USD"); err == nil {
+		t.Fatal("таблица осталась в кэше")
+	}
+}
+
+// Очистка ячеек в batchUpdate не отменяется, поэтому ошибка следующего шага сообщает RolledBack=false
+func TestFailedWriteAfterClearIsNotRolledBack(t *testing.T) {
+	b := &faultyBackend{SpreadsheetBackend: memoryBackend.New()}
+	sc := newTestControl(t, b, Options{})
+	if err := sc.setSheetData(b, testSheetData("USD", "1"), models.ScopeAll, 0); err != nil {
+		t.Fatalf("первая запись: %v", err)
+	}
+	// Размер прошлой записи потерян: перед записью листы очищаются запросами UpdateCells
+	for _, name := range []string{"soup", "RAW"} {
+		if err := sc.cache.RemoveSupFromCashed("USD", name); err != nil {
+			t.Fatalf("RemoveSupFromCashed: %v", err)
+		}
+	}
+
+	b.failWriteValues.Store(true)
+	err := sc.setSheetData(b, testSheetData("USD", "2"), models.ScopeAll, 0)
+	var stepErr *apperrors.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepWriteValues {
+		t.Fatalf("err = %v, want шаг %s", err, StepWriteValues)
+	}
+	if stepErr.RolledBack {
+		t.Fatal("RolledBack = true, хотя очищенные ячейки не восстановлены")
+	}
+}
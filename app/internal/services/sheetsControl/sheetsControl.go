@@ -2,328 +2,295 @@ package sheetsControl
 
 import (
 	"GoogleSheetW/internal/apperrors"
+	"GoogleSheetW/internal/backend"
 	"GoogleSheetW/internal/cache"
+	"GoogleSheetW/internal/keyLock"
+	"GoogleSheetW/internal/layout"
 	"GoogleSheetW/internal/logger"
 	"GoogleSheetW/internal/models"
-	"GoogleSheetW/internal/services/googleAPI"
+	"GoogleSheetW/internal/outbox"
 	"GoogleSheetW/internal/settings"
 	"context"
-	"errors"
 	"fmt"
 	"go.uber.org/zap"
-	"google.golang.org/api/drive/v3"
-	"google.golang.org/api/sheets/v4"
-	"strings"
+	"sync"
+	"time"
 )
 
-func dataTime(nameList string) []*sheets.ValueRange {
-	return []*sheets.ValueRange{
-		{
-			Range: fmt.Sprintf("'%s'!A1", nameList),
-			Values: [][]interface{}{
-				{"Дата записи:"},
-			},
-		},
-		{
-			Range: fmt.Sprintf("'%s'!A2:B2", nameList),
-			Values: [][]interface{}{
-				{"Текущая дата", "=NOW()- TIME(0, 0, 0)"},
-			},
-		},
-		{
-			Range: fmt.Sprintf("'%s'!A3:B3", nameList),
-			Values: [][]interface{}{
-				{"Минут прошло", "=IF(B1=\"\", \"\", ROUND((B2 - B1) * 1440,2))"},
-			},
-		},
-	}
-}
+// Options настройки SheetsControl
+type Options struct {
+	JobWorkers   int           // число обработчиков фоновых задач записи
+	JobQueueSize int           // максимальное число задач в очереди
+	JobRetention time.Duration // сколько хранить отчёт о завершённой задаче
+	BulkWorkers  int           // сколько валют пакетного запроса записывается одновременно
 
-func rawDataFilterFunc() []*sheets.ValueRange {
-	return []*sheets.ValueRange{
-		{
-			Range: "RAW_filter!A6",
-			Values: [][]interface{}{
-				{"=QUERY(RAW!$A4:O,\"select A,B,C,D,E,F,G,H,I,J,K,L,M,N,O\")"},
-			},
-		},
-	}
-}
+	Outbox               *outbox.Outbox // журнал исходящих данных; nil отключает журнал
+	OutboxReplayInterval time.Duration  // как часто повторять отправку из журнала
 
-func toInterface2D(data [][]string) [][]interface{} {
-	result := make([][]interface{}, len(data))
-	for i, row := range data {
-		result[i] = make([]interface{}, len(row))
-		for j := range row {
-			result[i][j] = row[j]
-		}
-	}
-	return result
-}
+	CacheRefreshInterval time.Duration // как часто сверять кэш с хранилищем; 0 - только при запуске
+	AdoptUntagged        bool          // отмечать таблицы без метки как таблицы сервиса по их названию
 
-func soupToSheet(soup models.Soup) []*sheets.ValueRange {
-	return []*sheets.ValueRange{
-		{
-			Range: fmt.Sprintf("'%s'!A5", soup.Name),
-			Values: [][]interface{}{
-				{"Fixed Price:", soup.FixedPrice},
-				{"Best Price:", soup.BestPrice},
-				{"Best Price Link", soup.BestPriceLink},
-				{"Money Supply:", soup.MoneySupply},
-				{"Выборка из:", soup.AverageSize},
-			},
-		},
-		{
-			Range: fmt.Sprintf("'%s'!B1", soup.Name),
-			Values: [][]interface{}{
-				{soup.Date},
-			},
-		},
-		{
-			Range: fmt.Sprintf("'%s'!A10", soup.Name),
-			Values: func() [][]interface{} {
-				var ans [][]interface{}
-				for _, InfoFilter := range soup.InfoFilters {
-					// Преобразуем массив банков в строку через запятую
-					banksString := strings.Join(InfoFilter.BanksName, ", ")
-					ans = append(ans,
-						[]interface{}{"Биржа:", InfoFilter.Exchange},
-						[]interface{}{"Банки:", banksString},
-						[]interface{}{"Выполненных заказов", InfoFilter.MonthOrder},
-						[]interface{}{"Процент выполненных заказов", InfoFilter.MonthFinishRate},
-						[]interface{}{"Макс. минимальная сумма транзакции", InfoFilter.MaxLowSingleTransAmount},
-						[]interface{}{"Мин. максимальная сумма транзакции", InfoFilter.MinHighSingleTransAmount},
-						[]interface{}{"Размер выборки", InfoFilter.AverageSize},
-						[]interface{}{"_______________", "________________"},
-					)
-				}
-				ans = append(ans,
-					[]interface{}{
-						"Exchange:", "Fiat", "Asset",
-						"TradeType", "NickName", "Price",
-						"MonthOrderCount", "MonthFinishRate",
-						"UserType", "MaxSingleTransAmount",
-						"MinSingleTransAmount", "LastQuantity",
-						"Link", "PaymentMethod", "monthFinishRate"})
-				ans = append(ans, toInterface2D(soup.Data)...)
-				return ans
-			}(),
-		},
-	}
+	FolderTemplate string            // шаблон подпапок для новых таблиц, например "{region}/{year}"; пусто - без подпапок
+	FiatRegions    map[string]string // fiat -> регион для {region}
+
+	TemplateSpreadsheetID string // таблица-шаблон, копией которой создаются таблицы валют; пусто - таблицы создаются пустыми
+	TemplateSheetName     string // лист шаблона, копией которого создаются листы супов
 
+	Layout     *layout.Layout // раскладка листов; nil - встроенная
+	RawMaxRows int            // строк данных на листе RAW; остальные переносятся на RAW_2, RAW_3...; 0 - без ограничения
+
+	RawWindowRows int           // сколько последних строк RAW хранить при дозаписи; 0 - без ограничения
+	RawWindowAge  time.Duration // строки RAW старше этого возраста удаляются при дозаписи; 0 - без ограничения
+	RawTimeColumn int           // столбец строки RAW (с 0) со временем для RawWindowAge
 }
 
 type SheetsControl struct {
-	ctx      context.Context
-	sheetSrv *sheets.Service
-	driveSrv *drive.Service
-	cache    cache.Cache
-	log      *zap.SugaredLogger
+	ctx     context.Context
+	backend backend.SpreadsheetBackend
+	cache   cache.Cache
+	writes  *coalescer
+	fiats   keyLock.Locks // записи одной валюты с разными ключами очереди (суп, RAW, все данные) не выполняются одновременно
+	jobs    *jobQueue
+	bulk    int
+	outbox  *outbox.Outbox
+	log     *zap.SugaredLogger
+
+	adoptUntagged  bool
+	folderTemplate string
+	fiatRegions    map[string]string
+	reconcileMu    sync.Mutex // сверки кэша с хранилищем не выполняются одновременно
+
+	templateSpreadsheetID string
+	templateSheetName     string
+
+	layout     *layout.Layout
+	rawMaxRows int
+
+	rawWindowRows int
+	rawWindowAge  time.Duration
+	rawTimeColumn int
 }
 
-func New(ctx context.Context, cache cache.Cache, pathToCredentialsFile string) *SheetsControl {
+func New(ctx context.Context, cache cache.Cache, backend backend.SpreadsheetBackend, opts Options) *SheetsControl {
 	log := logger.Get()
-	cred, err := googleAPI.GetCredentials(ctx, pathToCredentialsFile)
-	if err != nil {
-		log.Errorw("Ошибка создания SheetsControl: ", err)
-		panic(err)
+	ans := SheetsControl{
+		ctx:     ctx,
+		backend: backend,
+		cache:   cache,
+		writes:  newCoalescer(),
+		jobs:    newJobQueue(opts.JobQueueSize, opts.JobRetention),
+		bulk:    max(opts.BulkWorkers, 1),
+		outbox:  opts.Outbox,
+		log:     log,
+
+		adoptUntagged:  opts.AdoptUntagged,
+		folderTemplate: opts.FolderTemplate,
+		fiatRegions:    opts.FiatRegions,
+
+		templateSpreadsheetID: opts.TemplateSpreadsheetID,
+		templateSheetName:     opts.TemplateSheetName,
+
+		layout:     opts.Layout,
+		rawMaxRows: opts.RawMaxRows,
+
+		rawWindowRows: opts.RawWindowRows,
+		rawWindowAge:  opts.RawWindowAge,
+		rawTimeColumn: opts.RawTimeColumn,
 	}
-	sheetSrv, err := googleAPI.GetSheetsService(ctx, cred)
-	if err != nil {
-		log.Errorw("Ошибка создания SheetsControl: ", err)
-		panic(err)
+	if ans.layout == nil {
+		ans.layout = layout.Default()
 	}
-	driveSrv, err := googleAPI.GetDriveService(ctx, cred)
-	if err != nil {
-		log.Errorw("Ошибка создания SheetsControl: ", err)
-		panic(err)
+	switch {
+	case ans.cacheIsWarm() && opts.CacheRefreshInterval > 0:
+		// Кэш восстановлен из снимка: сервис готов сразу, а сверку выполнит периодическое обновление;
+		// расхождения до него исправляет запись (writeWithRepair)
+		log.Infow("Кэш загружен из сохранённого снимка", "refresh_interval", opts.CacheRefreshInterval)
+	case ans.cacheIsWarm():
+		// Периодического обновления нет, поэтому снимок сверяется с хранилищем один раз, в фоне
+		log.Info("Кэш загружен из сохранённого снимка, обновление выполняется в фоне")
+		go func() {
+			if _, err := ans.Reconcile(); err != nil {
+				log.Errorw("Ошибка фонового обновления кэша", "error", err)
+			}
+		}()
+	default:
+		if _, err := ans.Reconcile(); err != nil {
+			log.Errorw("Ошибка создания SheetsControl", "error", err)
+			panic(err)
+		}
 	}
-	ans := SheetsControl{
-		ctx:      ctx,
-		sheetSrv: sheetSrv,
-		driveSrv: driveSrv,
-		cache:    cache,
-		log:      log,
+	ans.startReconcile(opts.CacheRefreshInterval)
+	ans.startJobWorkers(opts.JobWorkers)
+	ans.startOutboxReplay(opts.OutboxReplayInterval)
+	return &ans
+}
+
+// cacheIsWarm сообщает, есть ли в кэше таблицы (например, загруженные из файла)
+func (sc *SheetsControl) cacheIsWarm() bool {
+	ids, err := sc.cache.GetAllIDs()
+	return err == nil && len(ids) > 0
+}
+
+// SetSheetData записывает данные валюты. Записи одной валюты выполняются по очереди;
+// если до начала записи пришли более новые данные, эта запись пропускается с OutcomeSuperseded.
+// При включённом журнале данные сначала сохраняются на диск, и при ошибке записи
+// возвращается OutcomeDeferred вместе с ошибкой: данные будут отправлены повторно
+func (sc *SheetsControl) SetSheetData(data models.SheetData) (WriteOutcome, error) {
+	return sc.write(data, models.ScopeAll)
+}
+
+// UpsertSoup записывает один лист супа, не трогая RAW и другие листы. Записи одного листа выполняются
+// по очереди так же, как SetSheetData
+func (sc *SheetsControl) UpsertSoup(fiat string, soup models.Soup) (WriteOutcome, error) {
+	if soup.Name == "" {
+		return "", &apperrors.ValidationError{Field: "name", Msg: "не задано имя листа"}
 	}
-	err = ans.update()
+	if isRawSheet(soup.Name) || soup.Name == "RAW_filter" || soup.Name == sc.templateSheetName && sc.templateSpreadsheetID != "" {
+		return "", &apperrors.ValidationError{Field: "name", Msg: fmt.Sprintf("лист %s не является листом супа", soup.Name)}
+	}
+	return sc.write(models.SheetData{Fiat: fiat, SoupList: []models.Soup{soup}}, models.ScopeSoup)
+}
+
+// UpsertRaw записывает только листы RAW (с RAW_2, RAW_3... и формулой RAW_filter), не трогая листы супов
+func (sc *SheetsControl) UpsertRaw(fiat string, raw models.RAWData) (WriteOutcome, error) {
+	return sc.write(models.SheetData{Fiat: fiat, RAWData: raw}, models.ScopeRaw)
+}
+
+// AppendRaw дописывает строки под данными листа RAW, сохраняя историю прошлых записей в пределах окна
+// RawWindowRows/RawWindowAge; строки вне окна удаляются
+func (sc *SheetsControl) AppendRaw(fiat string, raw models.RAWData) (WriteOutcome, error) {
+	return sc.write(models.SheetData{Fiat: fiat, RAWData: raw}, models.ScopeRawAppend)
+}
+
+// write сохраняет данные в журнал исходящих данных и записывает листы из scope
+func (sc *SheetsControl) write(data models.SheetData, scope models.WriteScope) (WriteOutcome, error) {
+	seq, err := sc.saveToOutbox(data, scope)
 	if err != nil {
-		log.Errorw("Ошибка создания SheetsControl: ", err)
-		panic(err)
+		return "", err
 	}
-	return &ans
+	return sc.deliver(sc.backend, data, scope, seq)
 }
 
-func (sc *SheetsControl) update() error {
-	// Получаем карту с именами и ID всех таблиц
-	sheetIDMap, err := googleAPI.GetAllSheetIDByName(sc.driveSrv)
+// setSheetData выполняет запись через переданное хранилище (например, с журналом вызовов для задачи).
+// Запись разбита на шаги (saga): если шаг не удался, созданные этой записью листы удаляются (новая таблица -
+// только если не удалась её настройка), а ошибка *apperrors.StepError сообщает, какой шаг не выполнен
+func (sc *SheetsControl) setSheetData(b backend.SpreadsheetBackend, data models.SheetData, scope models.WriteScope, seq uint64) error {
+	s := newSaga(sc.log, data.Fiat)
+	var sheetID string
+	err := s.run(StepGetSpreadsheet, func() (err error) {
+		sheetID, err = sc.cache.GetOrCreateIDbyFiat(data.Fiat, func() (string, error) {
+			return sc.newSpreadsheet(b, s, data.Fiat)
+		})
+		return err
+	})
 	if err != nil {
-		sc.log.Errorw("Ошибка получения id таблиц: ", err)
 		return err
 	}
 
-	// Добавляем информацию о таблицах в кэш
-	err = sc.cache.AddToCash(sheetIDMap)
+	if scope == models.ScopeRawAppend {
+		appended, err := sc.appendRaw(b, s, sheetID, data.Fiat, data.RAWData, seq)
+		if err != nil || appended {
+			return err
+		}
+		// Листа RAW ещё нет: он создаётся обычной записью строк, попадающих в окно
+		data.RAWData.Data = data.RAWData.Data[sc.rawWindowStart(data.RAWData.Data, time.Now()):]
+		scope = models.ScopeRaw
+	}
+
+	var plan *writePlan
+	err = s.run(StepPlanWrite, func() (err error) {
+		plan, err = sc.planWrite(b, sheetID, data, scope)
+		return err
+	})
 	if err != nil {
-		sc.log.Errorw("Ошибка инициализации кеша таблиц: ", err)
 		return err
 	}
-
-	// Проходим по всем таблицам и получаем список листов для каждой
-	for fiat, spreadsheetID := range sheetIDMap {
-		// Получаем список всех листов в таблице
-		spreadsheet, err := sc.sheetSrv.Spreadsheets.Get(spreadsheetID).Do()
+	if len(plan.requests) > 0 {
+		err = s.run(StepUpdateStructure, func() error {
+			_, err := b.BatchUpdate(sheetID, plan.requests)
+			return err
+		})
 		if err != nil {
-			sc.log.Warnw("Ошибка получения списка листов для таблицы",
-				"fiat", fiat,
-				"spreadsheetID", spreadsheetID,
-				"error", err)
-			continue
+			return err
+		}
+		// Новые листы удаляются, если запись не удалась. Очищенные ячейки существующих листов не
+		// восстанавливаются, поэтому после такой очистки ошибка записи сообщает RolledBack=false
+		if ids := createdSheetIDs(plan.requests); len(ids) > 0 {
+			s.compensate(StepUpdateStructure, func() error {
+				_, err := b.BatchUpdate(sheetID, deleteSheetRequests(ids))
+				return err
+			})
+		}
+		if hasClearRequests(plan.requests) {
+			s.irreversibleChange()
 		}
+	}
+	err = s.run(StepWriteValues, func() error {
+		return b.WriteValues(sheetID, plan.values)
+	})
+	if err != nil {
+		return err
+	}
+	s.commit()
 
-		// Добавляем каждый лист в кэш
-		for _, sheet := range spreadsheet.Sheets {
-			sheetName := sheet.Properties.Title
-			err = sc.cache.SetSupInCashed(fiat, sheetName)
-			if err != nil {
-				sc.log.Warnw("Ошибка добавления листа в кэш",
-					"fiat", fiat,
-					"sheetName", sheetName,
-					"error", err)
+	// Листы попадают в кэш только после записи: если она не удалась, следующая запись найдёт их
+	// в списке листов и заново запишет подписи
+	err = s.run(StepUpdateCache, func() error {
+		for _, name := range plan.newSheets {
+			if err := sc.cache.SetSupInCashed(data.Fiat, name); err != nil {
+				return err
 			}
 		}
-
-		sc.log.Infow("Обновлены данные о листах в таблице",
-			"fiat", fiat,
-			"sheets_count", len(spreadsheet.Sheets))
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-
-	sc.log.Info("Кэш успешно обновлен")
+	sc.saveSheetStates(data.Fiat, plan.extents, plan.grids, plan.sheetIDs)
 	return nil
 }
 
-func (sc *SheetsControl) SetSheetData(data models.SheetData) error {
-	sheetID, err := sc.cache.GetIDbyFiat(data.Fiat)
+// newSpreadsheet создаёт таблицу валюты с меткой, папкой и доступом. Если шаг настройки не удался, таблица
+// удаляется, чтобы не оставлять наполовину настроенную таблицу. Настроенная таблица остаётся, даже если запись
+// данных потом не удастся: повторная отправка (например, из журнала) запишет данные в неё, а не создаст новую
+// таблицу и не разошлёт приглашения ещё раз
+func (sc *SheetsControl) newSpreadsheet(b backend.SpreadsheetBackend, s *saga, fiat string) (string, error) {
+	var id string
+	err := s.run(StepCreateSpreadsheet, func() (err error) {
+		id, err = sc.createSpreadsheet(b, fiat)
+		return err
+	})
 	if err != nil {
-		switch {
-		case errors.As(err, &apperrors.ErrCacheNotFound):
-			{
-				_emails := settings.GetSettings().GetEmails()
-				sheetID, err = googleAPI.CreateSheetWithPermission(sc.sheetSrv, sc.driveSrv, data.Fiat, &_emails)
-				if err != nil {
-					sc.log.Errorw("Ошибка создания таблицы: ", err)
-					return err
-				}
-				err := sc.cache.SetIDbyFiat(data.Fiat, sheetID)
-				if err != nil {
-					sc.log.Errorw("Ошибка добавления  id таблицы в hash: ", err)
-					return err
-				}
-				break
-			}
-		default:
-			{
-				sc.log.Errorw("Неизвестная ошибка", err)
-				return err
-
-			}
-		}
+		return "", err
 	}
-	var ans []*sheets.ValueRange
-	var delAns []string
-	for _, soup := range data.SoupList {
-		if ok, err := sc.cache.IsSupInCashed(data.Fiat, soup.Name); err == nil {
-			if !ok {
-				err := googleAPI.CreateSheetList(sc.sheetSrv, sheetID, soup.Name)
-				if err != nil {
-					sc.log.Errorw(fmt.Sprintf("Ошибка создания листа для %s %s c ID %s ", data.Fiat, soup.Name, sheetID), err)
-					return err
-				}
-				err = sc.cache.SetSupInCashed(data.Fiat, soup.Name)
-				if err != nil {
-					sc.log.Errorw(fmt.Sprintf("Ошибка добавление в cache название супа %s %s"), data.Fiat, soup.Name)
-					return err
-				}
-				err = googleAPI.WriteToSheet(sc.sheetSrv, sheetID, dataTime(soup.Name))
-				if err != nil {
-					sc.log.Errorw(fmt.Sprintf("Ошибка записи данных в лист %s %s", data.Fiat, soup.Name), err)
-					return err
-				}
-			}
-
-		} else {
-			sc.log.Errorw(fmt.Sprintf("ошибка чтения кэша %s %s", data.Fiat, soup.Name), err)
+	s.compensate(StepCreateSpreadsheet, func() error {
+		if err := b.DeleteSpreadsheet(id); err != nil {
 			return err
 		}
-		ans = append(ans, soupToSheet(soup)...)
-		delAns = append(delAns, fmt.Sprintf("'%s'!A10:O100", soup.Name))
-	}
-	if ok, err := sc.cache.IsSupInCashed(data.Fiat, "RAW"); err == nil {
-		if !ok {
-			err := googleAPI.CreateSheetList(sc.sheetSrv, sheetID, "RAW")
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка создания листа для %s  %s c ID %s ", data.Fiat, "RAW", sheetID), err)
-				return err
-			}
-			err = googleAPI.CreateSheetList(sc.sheetSrv, sheetID, "RAW_filter")
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка создания листа для %s  %s c ID %s ", data.Fiat, "RAW_filter", sheetID), err)
-				return err
-			}
-
-			err = sc.cache.SetSupInCashed(data.Fiat, "RAW")
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка добавление в cache %s RAW"), data.Fiat)
-				return err
-			}
-
-			err = googleAPI.WriteToSheet(sc.sheetSrv, sheetID, dataTime("RAW"))
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка записи данных в лист %s %s", data.Fiat, "RAW"), err)
-				return err
-			}
-			idList, err := googleAPI.SheetIDByName(sc.sheetSrv, sheetID, "RAW_filter")
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка получения ID листа RAW_filter %s", data.Fiat), err)
-				return err
-			}
-			err = googleAPI.CreateSheetFilter(sc.sheetSrv, sheetID, idList, 0, 14, 5, 4500)
-			if err != nil {
-				sc.log.Errorw(fmt.Sprintf("Ошибка создания фильтра в листе RAW_filter %s", data.Fiat), err)
-				return err
-			}
-
-			ans = append(ans, rawDataFilterFunc()...)
-		}
-		rawData := []*sheets.ValueRange{
-			{
-				Range: fmt.Sprintf("RAW!B1"),
-				Values: [][]interface{}{
-					{data.RAWData.Date},
-				},
-			},
-			{
-				Range:  fmt.Sprintf("RAW!A4:Q4500"),
-				Values: toInterface2D(data.RAWData.Data),
-			},
-		}
-		ans = append(ans, rawData...)
-		delAns = append(delAns, "RAW!A4:Q4500")
+		return sc.cache.RemoveFiatFromCache(fiat)
+	})
 
-	} else {
-		sc.log.Errorw(fmt.Sprintf("ошибка чтения кэша %s RawData", data.Fiat), err)
-		return err
+	// Метка отличает таблицы сервиса от прочих файлов, доступных сервисному аккаунту
+	err = s.run(StepTagSpreadsheet, func() error {
+		return b.TagSpreadsheet(id, fiat)
+	})
+	if err != nil {
+		return "", err
 	}
-	err = googleAPI.DeleteFromSheet(sc.sheetSrv, sheetID, delAns)
+	err = s.run(StepPlaceSpreadsheet, func() error {
+		return sc.placeSpreadsheet(b, fiat, id)
+	})
 	if err != nil {
-		sc.log.Errorw(fmt.Sprintf("Ошибка удаления данных в листе %s", data.Fiat), err)
-		return err
+		return "", err
 	}
-	err = googleAPI.WriteToSheet(sc.sheetSrv, sheetID, ans)
+	err = s.run(StepAddPermission, func() error {
+		return b.AddPermission(id, settings.GetSettings().GetEmails())
+	})
 	if err != nil {
-		sc.log.Errorw(fmt.Sprintf("Ошибка записи данных в лист %s", data.Fiat), err)
-		return err
+		return "", err
 	}
-	return nil
+	s.keep(StepCreateSpreadsheet)
+	return id, nil
 }
 
 // DeleteSheet удаляет лист из таблицы
@@ -351,8 +318,8 @@ func (sc *SheetsControl) DeleteSheet(fiat, sheetName string) error {
 		return fmt.Errorf("лист %s не найден в таблице %s", sheetName, fiat)
 	}
 
-	// Удаляем лист через Google Sheets API
-	err = googleAPI.DeleteSheetByName(sc.sheetSrv, sheetID, sheetName)
+	// Удаляем лист из хранилища таблиц
+	err = sc.backend.DeleteSheet(sheetID, sheetName)
 	if err != nil {
 		sc.log.Errorw("Ошибка удаления листа через API", "fiat", fiat, "sheet_name", sheetName, "error", err)
 		return fmt.Errorf("не удалось удалить лист %s: %v", sheetName, err)
@@ -381,8 +348,8 @@ func (sc *SheetsControl) DeleteSpreadsheet(fiat string) error {
 		return fmt.Errorf("таблица для %s не найдена: %v", fiat, err)
 	}
 
-	// Удаляем таблицу через Google Drive API
-	err = googleAPI.DeleteSpreadsheetByID(sc.driveSrv, sheetID)
+	// Удаляем таблицу из хранилища
+	err = sc.backend.DeleteSpreadsheet(sheetID)
 	if err != nil {
 		sc.log.Errorw("Ошибка удаления таблицы через API", "fiat", fiat, "error", err)
 		return fmt.Errorf("не удалось удалить таблицу %s: %v", fiat, err)
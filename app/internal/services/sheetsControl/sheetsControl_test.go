@@ -0,0 +1,62 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend"
+	"GoogleSheetW/internal/cache/localCache"
+	"GoogleSheetW/internal/models"
+	"context"
+	"errors"
+	"google.golang.org/api/sheets/v4"
+	"sync/atomic"
+	"testing"
+)
+
+// errTest - ошибка, которую тесты подставляют вместо ошибки Google
+var errTest = errors.New("test error")
+
+// newTestControl создаёт SheetsControl над хранилищем b с пустым кэшем в памяти
+func newTestControl(t *testing.T, b backend.SpreadsheetBackend, opts Options) *SheetsControl {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return New(ctx, localCache.New(localCache.Snapshot{}, nil), b, opts)
+}
+
+// testSheetData возвращает данные валюты с одним листом супа и двумя строками RAW
+func testSheetData(fiat, price string) models.SheetData {
+	return models.SheetData{
+		Fiat: fiat,
+		SoupList: []models.Soup{{
+			Name: "soup",
+			Date: "2025-01-30",
+			Data: [][]string{{"Exchange", "Price"}, {"Binance", price}},
+		}},
+		RAWData: models.RAWData{
+			Date: "2025-01-30",
+			Data: [][]string{{"r1", price}, {"r2", price}},
+		},
+	}
+}
+
+// faultyBackend подставляет ошибки в вызовы хранилища и считает выдачу доступа
+type faultyBackend struct {
+	backend.SpreadsheetBackend
+	failWriteValues   atomic.Bool
+	failAddPermission atomic.Bool
+	permissions       atomic.Int32
+}
+
+func (f *faultyBackend) WriteValues(spreadsheetID string, data []*sheets.ValueRange) error {
+	if f.failWriteValues.Load() {
+		return errTest
+	}
+	return f.SpreadsheetBackend.WriteValues(spreadsheetID, data)
+}
+
+func (f *faultyBackend) AddPermission(spreadsheetID string, emails []string) error {
+	if f.failAddPermission.Load() {
+		return errTest
+	}
+	f.permissions.Add(1)
+	return f.SpreadsheetBackend.AddPermission(spreadsheetID, emails)
+}
@@ -0,0 +1,95 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/models"
+	"fmt"
+	"sort"
+)
+
+// spreadsheetURL возвращает ссылку на таблицу в Google Sheets
+func spreadsheetURL(spreadsheetID string) string {
+	return fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/edit", spreadsheetID)
+}
+
+// Spreadsheets возвращает таблицы всех валют с их листами из кэша.
+// С refresh кэш сначала сверяется с хранилищем
+func (sc *SheetsControl) Spreadsheets(refresh bool) ([]models.FiatSpreadsheet, error) {
+	if refresh {
+		if _, err := sc.Reconcile(); err != nil {
+			return nil, err
+		}
+	}
+	ids, err := sc.cache.GetAllIDs()
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения кэша таблиц", "error", err)
+		return nil, err
+	}
+
+	fiats := make([]string, 0, len(ids))
+	for fiat := range ids {
+		fiats = append(fiats, fiat)
+	}
+	sort.Strings(fiats)
+	result := make([]models.FiatSpreadsheet, 0, len(fiats))
+	for _, fiat := range fiats {
+		spreadsheet, err := sc.cachedSpreadsheet(fiat, ids[fiat])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, spreadsheet)
+	}
+	return result, nil
+}
+
+// Spreadsheet возвращает таблицу валюты с её листами из кэша.
+// С refresh список листов, их ID и размеры сеток сначала перечитываются из хранилища
+func (sc *SheetsControl) Spreadsheet(fiat string, refresh bool) (models.FiatSpreadsheet, error) {
+	spreadsheetID, err := sc.spreadsheetID(fiat)
+	if err != nil {
+		return models.FiatSpreadsheet{}, err
+	}
+	if refresh {
+		sc.reconcileMu.Lock()
+		_, _, err = sc.syncSheets(fiat, spreadsheetID)
+		sc.reconcileMu.Unlock()
+		if err != nil {
+			return models.FiatSpreadsheet{}, err
+		}
+	}
+	return sc.cachedSpreadsheet(fiat, spreadsheetID)
+}
+
+// cachedSpreadsheet собирает сведения о таблице и её листах из кэша
+func (sc *SheetsControl) cachedSpreadsheet(fiat, spreadsheetID string) (models.FiatSpreadsheet, error) {
+	sups, err := sc.cache.GetSupsByFiat(fiat)
+	if err != nil {
+		sc.log.Errorw("Ошибка чтения листов из кэша", "fiat", fiat, "error", err)
+		return models.FiatSpreadsheet{}, err
+	}
+	sort.Strings(sups)
+
+	result := models.FiatSpreadsheet{
+		Fiat:          fiat,
+		SpreadsheetID: spreadsheetID,
+		URL:           spreadsheetURL(spreadsheetID),
+		Sheets:        make([]models.SheetTab, 0, len(sups)),
+	}
+	for _, name := range sups {
+		tab := models.SheetTab{Title: name}
+		state, ok, err := sc.cache.GetSheetState(fiat, name)
+		if err != nil {
+			sc.log.Warnw("Ошибка чтения состояния листа из кэша", "fiat", fiat, "sheetName", name, "error", err)
+		}
+		if err == nil && ok {
+			tab.SheetID = state.SheetID
+			tab.RowCount = state.GridRows
+			tab.ColumnCount = state.GridCols
+			if state.Written() {
+				updatedAt := state.UpdatedAt
+				tab.LastWriteAt = &updatedAt
+			}
+		}
+		result.Sheets = append(result.Sheets, tab)
+	}
+	return result, nil
+}
@@ -0,0 +1,13 @@
+package sheetsControl
+
+import (
+	"GoogleSheetW/internal/backend"
+)
+
+// createSpreadsheet создаёт таблицу валюты: копией таблицы-шаблона, если она задана, иначе пустую
+func (sc *SheetsControl) createSpreadsheet(b backend.SpreadsheetBackend, fiat string) (string, error) {
+	if sc.templateSpreadsheetID == "" {
+		return b.CreateSpreadsheet(fiat)
+	}
+	return b.CopySpreadsheet(sc.templateSpreadsheetID, fiat)
+}